@@ -0,0 +1,141 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// avoidCollision check the branch name against the branches in the
+// repository we are in. Names that are taken are reported, reused with
+// --reuse, or given a suffix with --unique
+func avoidCollision(
+	c *cli.Context,
+	conf config,
+	branchName string,
+) (string, *cli.ExitError) {
+	unique := c.GlobalBool(argumentUnique)
+	reuse := c.GlobalBool(argumentReuse)
+
+	if unique && reuse {
+		return "", cli.NewExitError(
+			"--"+argumentUnique+" and --"+argumentReuse+
+				" can't be used together",
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	refs, err := gitBranchRefs()
+	if err != nil {
+		return branchName, nil
+	}
+
+	names := branchhelper.BranchNames{
+		Existing: branchhelper.ParseBranchRefs(refs),
+	}
+
+	existing, taken := names.Find(branchName)
+
+	switch {
+	case !taken:
+		return branchName, nil
+	case reuse:
+		fmt.Fprintf(os.Stderr, "using existing branch %s\n", existing)
+
+		return branchName, nil
+	case !unique:
+		fmt.Fprintf(
+			os.Stderr,
+			"branch %s already exists, use --%s or --%s\n",
+			existing,
+			argumentUnique,
+			argumentReuse,
+		)
+
+		return branchName, nil
+	}
+
+	suffix := c.GlobalString(argumentUniqueSuffix)
+	if !c.GlobalIsSet(argumentUniqueSuffix) && conf.BranchSuffix != "" {
+		suffix = conf.BranchSuffix
+	}
+
+	names.Suffix, err = (&branchhelper.Jira{Config: conf.TemplateConfig}).
+		NewTemplate().
+		Parse(suffix)
+
+	if err != nil {
+		return "", cli.NewExitError(
+			errors.Wrap(err, "failed to parse branch suffix").Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	if strings.Contains(suffix, ".Initials") {
+		names.Initials = branchhelper.Initials(gitUserName())
+	}
+
+	uniqueName, err := names.Unique(branchName)
+
+	if err != nil {
+		return "", cli.NewExitError(
+			errors.Wrap(err, "failed to build branch name").Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	fmt.Fprintf(os.Stderr, "branch %s already exists, using %s\n", existing, uniqueName)
+
+	return uniqueName, nil
+}
+
+// gitBranchRefs the local and remote-tracking branches of the repository we
+// are in, failing if we aren't in one
+func gitBranchRefs() ([]string, error) {
+	out, err := exec.Command(
+		"git",
+		"for-each-ref",
+		"--format=%(refname)",
+		"refs/heads",
+		"refs/remotes",
+	).Output()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(out), "\n"), nil
+}
+
+// gitUserName the name git uses for the author of commits
+func gitUserName() string {
+	out, err := exec.Command("git", "config", "--get", "user.name").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,66 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/pkg/errors"
+)
+
+// defaultConfigFile is the configuration file read when none is given, from
+// the users home directory
+const defaultConfigFile = ".jira-branch-helper.json"
+
+// config is the contents of the configuration file
+type config struct {
+	Instances    branchhelper.Instances `json:"instances"`
+	Transition   string                 `json:"transition"`
+	BranchSuffix string                 `json:"branchSuffix"`
+	branchhelper.TemplateConfig
+}
+
+// loadConfig reads the configuration file. If no path is given, the default
+// file is used if it exists, and an empty configuration if it doesn't
+func loadConfig(path string) (config, error) {
+	conf := config{}
+
+	if path == "" {
+		path = filepath.Join(os.Getenv("HOME"), defaultConfigFile)
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return conf, nil
+		}
+	}
+
+	rawConf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return conf, errors.Wrap(err, "failed to read config file")
+	}
+
+	if err := json.Unmarshal(rawConf, &conf); err != nil {
+		return conf, errors.Wrap(err, "failed to parse config file")
+	}
+
+	return conf, nil
+}
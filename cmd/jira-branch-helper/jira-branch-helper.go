@@ -19,9 +19,13 @@
 package main
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
 	"github.com/andygrunwald/go-jira"
@@ -36,6 +40,8 @@ const (
 	errorExitCodeBranchNameBuildFailure
 	errorExitCodeCouldNotParseIssue
 	errorExitCodeBranchNameWriteError
+	errorExitCodeConfigFailure
+	errorExitCodeJiraUpdateFailure
 )
 
 const (
@@ -53,8 +59,85 @@ const (
 	argumentJiraCookiePassword = "jira-password"
 	// argumentJiraEndpoint is the option to set jira's URL
 	argumentJiraEndpoint = "jira-endpoint"
+	// argumentJiraAPIVersion is the option to set the version of the Jira REST
+	// API to use
+	argumentJiraAPIVersion = "jira-api-version"
 	// argumentTemplate is the option to set the template to generate the branch
 	argumentTemplate = "template"
+	// argumentTemplateFile is the option to read the template to generate the
+	// branch from a file
+	argumentTemplateFile = "template-file"
+	// argumentTemplateDir is the option to set a directory of templates that
+	// can be included from the branch template
+	argumentTemplateDir = "template-dir"
+	// argumentConfig is the option to set the path to the configuration file
+	argumentConfig = "config"
+	// argumentTransition is the option to set the transition to perform on the
+	// issue once the branch name is built
+	argumentTransition = "transition"
+	// argumentDryRun is the option to show changes that would be made to Jira,
+	// or branches that would be deleted, rather than making them
+	argumentDryRun = "dry-run"
+	// argumentAssignSelf is the option to assign the issue to the user we are
+	// authenticated as
+	argumentAssignSelf = "assign-self"
+	// argumentForceAssign is the option to assign the issue even when it is
+	// assigned to someone else
+	argumentForceAssign = "force-assign"
+	// argumentLinkBranch is the option to add a remote link to the branch on
+	// the issue
+	argumentLinkBranch = "link-branch"
+	// argumentCommentBranch is the option to comment the branch name on the
+	// issue
+	argumentCommentBranch = "comment-branch"
+	// argumentRepositoryURL is the option to set the repository mentioned in
+	// links and comments
+	argumentRepositoryURL = "repository-url"
+	// argumentConnectTimeout is the option to set how long to wait for a
+	// connection to Jira
+	argumentConnectTimeout = "connect-timeout"
+	// argumentTimeout is the option to set how long a request to Jira can take
+	argumentTimeout = "timeout"
+	// argumentRetries is the option to set how many times to retry requests
+	// when Jira is busy or failing
+	argumentRetries = "retries"
+	// argumentCAFile is the option to trust more certificate authorities
+	argumentCAFile = "ca-file"
+	// argumentInsecureSkipVerify is the option to not check Jira's certificate
+	argumentInsecureSkipVerify = "insecure-skip-verify"
+	// argumentClientCert is the option to set the certificate to identify
+	// ourselves with
+	argumentClientCert = "client-cert"
+	// argumentClientKey is the option to set the private key of the client
+	// certificate
+	argumentClientKey = "client-key"
+	// argumentClientKeyPassphrase is the option to set the passphrase of an
+	// encrypted client key, rather than being asked for it
+	argumentClientKeyPassphrase = "client-key-passphrase"
+	// argumentTracker is the option to set the kind of issue tracker the
+	// issue is from
+	argumentTracker = "tracker"
+	// argumentTrackerEndpoint is the option to set the API of a tracker that
+	// isn't Jira, for self hosted trackers
+	argumentTrackerEndpoint = "tracker-endpoint"
+	// argumentTrackerToken is the option to set the token for the API of a
+	// tracker that isn't Jira
+	argumentTrackerToken = "tracker-token"
+	// argumentRecord is the option to record the requests to Jira, and its
+	// responses, to a file
+	argumentRecord = "record"
+	// argumentReplay is the option to answer requests to Jira from a
+	// recording, rather than contacting Jira
+	argumentReplay = "replay"
+	// argumentUnique is the option to add a suffix to branch names that are
+	// already taken in the repository
+	argumentUnique = "unique"
+	// argumentUniqueSuffix is the option to set the template for the suffix
+	// added to branch names that are taken
+	argumentUniqueSuffix = "unique-suffix"
+	// argumentReuse is the option to use a branch that already has the name,
+	// rather than making a new one
+	argumentReuse = "reuse"
 )
 
 // defaultTemplate is The default template to use for the branch
@@ -89,6 +172,48 @@ func main() {
 	Environment variables may be used in place of flags, parameters, see
 	parameters with [$ENV_NAME_HERE] at the end.
 
+	Requests to Jira use the proxy in the HTTPS_PROXY (or HTTP_PROXY)
+	environment variable, unless the host is listed in NO_PROXY. Requests are
+	retried when Jira is busy or failing, waiting as long as Jira asks
+
+	For proxies that require client certificates, give a PEM certificate
+	with --client-cert, and its key with --client-key. You will be asked for
+	the passphrase of encrypted keys, unless it is in
+	JIRA_BRANCH_HELPER_CLIENT_KEY_PASSPHRASE
+
+	Several Jira instances can be listed in the configuration file (by default
+	~/.jira-branch-helper.json). Issue keys are routed to the instance that
+	owns their project, and issue URLs to the instance on the same host
+
+	{
+	  "instances": [
+	    {
+	      "name": "cloud",
+	      "endpoint": "https://example.atlassian.net/",
+	      "projects": ["TST"],
+	      "basicAuthUsername": "user@example.com",
+	      "basicAuthPassword": "api-token",
+	      "apiVersion": "3",
+	      "template": "{{.Key | ToLower }}-{{.Fields.Summary | KebabCase }}"
+	    },
+	    {
+	      "name": "legacy",
+	      "endpoint": "https://example.com/jira/",
+	      "projects": ["OLD"],
+	      "username": "user",
+	      "password": "password"
+	    }
+	  ],
+	  "stopWords": {"en": ["a", "an", "the"]},
+	  "abbreviations": {"kubernetes": "k8s"}
+	}
+
+	"stopWords" replaces the built in stop words for a language, and
+	"abbreviations" are used in addition to the built in ones. A default
+	"transition" can be set for all instances, or for each one. Version 3 of
+	the Jira API ("apiVersion") is only available on Jira Cloud, and gives
+	descriptions and comments as rich text, see "PlainText"
+
 	The following functions are available for templating
 
 	* "Trim"               - Remove whitespace from start and end
@@ -102,6 +227,21 @@ func main() {
 	* "ScreamingSnakeCase" - Switch the CASING_TO_SNAKE
 	* "UpperCamelCase"     - Switch the CasingToCamel
 	* "UpperKebabCase"     - Switch the Casing-To-Kebab
+	* "RegexReplace"       - Replace by regular expression params: pattern,
+	                         replace-with
+	* "RemoveStopWords"    - Drop words like "a", "the", "of" params: optional
+	                         language (default "en", also "de", "fr", "es")
+	* "Abbreviate"         - Shorten words e.g. "authentication" to "auth"
+	* "FirstWords"         - Keep the first words params: number-of-words
+	* "Default"            - Use a value when the string is empty params: value
+	* "Split"              - Split a string into a list params: separator
+	* "Join"               - Join a list into a string params: separator
+	* "Slug"               - Make a string branch safe, e.g. "Bäume: γ 1!"
+	                         becomes "baume-1"
+	* "PlainText"          - The text of rich text from version 3 of the API
+	                         e.g. {{.Fields.Description | PlainText}}
+	* "Field"              - The value of a field by its name, for custom
+	                         fields e.g. {{Field "Epic Name" | KebabCase}}
 
 	The template format is as described here
 	https://golang.org/pkg/text/template/
@@ -109,6 +249,83 @@ func main() {
 	Templates look like this
 
 	{{.Key | ToLower }}-{{.Fields.Summary | Replace "A" "B" | KebabCase }}
+
+	As well as the fields of the issue, templates can use .Parent (for
+	sub-tasks), .Epic and .Sprint. These are only fetched from Jira if the
+	template uses them, and are empty if the issue doesn't have one
+
+	{{with .Parent}}{{.Key | ToLower}}-{{.Fields.Summary | Slug}}/{{end}}
+	{{- .Key | ToLower}}-{{.Fields.Summary | Slug}}
+
+	Issues can also come from GitHub, GitLab and Linear. The tracker is
+	worked out from issue URLs, or given with --tracker, and tokens for their
+	APIs with --tracker-token. Templates for these issues can use .Key,
+	.Title, .Type, .Labels and .URL, which Jira issues have too
+
+	$ jira-branch-helper https://github.com/org/repo/issues/12
+	12-ticket-title-goes-here
+
+	$ jira-branch-helper --tracker linear \
+	    --template '{{.Key | ToLower}}/{{.Title | Slug}}' ENG-12
+	eng-12/ticket-title-goes-here
+
+	Branches already in the repository, local or remote-tracking, are
+	reported on stderr if they have the same name. With --reuse the existing
+	branch is used, and with --unique a suffix is added to the name. The
+	suffix is a template, "-{{.Number}}" by default, and "branchSuffix" in
+	the configuration file sets it too
+
+	$ jira-branch-helper --unique TST-123
+	tst-123-ticket-title-goes-here-2
+
+	$ jira-branch-helper --unique --unique-suffix '-{{.Initials}}' TST-123
+	tst-123-ticket-title-goes-here-bat
+
+	Branch names can be served over HTTP, for tools that suggest them. Issues
+	are remembered for --cache-ttl, and Prometheus metrics are at /metrics.
+	The server listens on 127.0.0.1:8080 unless given a --listen address, and
+	logs in to Jira with basic auth
+
+	$ jira-branch-helper --jira-endpoint https://example.com/jira/ serve
+	$ curl http://localhost:8080/branch/TST-123
+	{"key":"TST-123","branch":"tst-123-ticket-title-goes-here"}
+
+	Jira webhooks for created and updated issues can be sent to /webhook when
+	the server has a --webhook-secret, and with --comment-branch the branch
+	name is commented on the issue
+
+	The verify command checks a branch references an issue that exists and
+	isn't done, for CI or a pre-push hook. Each problem has its own exit code,
+	see "jira-branch-helper verify --help"
+
+	$ jira-branch-helper verify --project TST feature/tst-123-login-page
+	feature/tst-123-login-page references TST-123
+
+	The prune command deletes the branches merged into the main branch whose
+	issues are done, for the projects of the configured instances or those
+	given with --project. Check what it would delete with --dry-run first
+
+	$ jira-branch-helper --dry-run prune --project TST --remote
+	would delete origin/tst-123-ticket-title-goes-here (TST-123 is Done)
+
+	To report a problem with an issue without access to your Jira, record the
+	requests made to Jira, and its responses. Credentials are left out of
+	recordings, but the issue's details are in them, so check before sharing
+	one. The run can then be replayed with the same arguments
+
+	$ jira-branch-helper --record tst-123.json TST-123
+	$ jira-branch-helper --replay tst-123.json TST-123
+
+	Templates can be kept in files with --template-file. Every *.tmpl file
+	in --template-dir is parsed too, so templates can share named partials
+
+	$ cat templates/prefix.tmpl
+	{{define "prefix"}}{{.Fields.Type.Name | ToLower}}/{{.Key}}{{end}}
+
+	$ jira-branch-helper --template-dir templates \
+	    --template '{{template "prefix" .}}-{{.Fields.Summary | Slug}}' \
+	    TST-123
+	story/TST-123-ticket-title-goes-here
 	`
 	app.Copyright = `
 	jira-branch-helper  Copyright (C) 2017  Billie Alice Thompson
@@ -145,14 +362,172 @@ func main() {
 			Name:   argumentJiraEndpoint,
 			Usage:  "Jira's URL",
 		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_API_VERSION",
+			Name:   argumentJiraAPIVersion,
+			Usage:  "The version of the Jira REST API to use, \"2\" or \"3\"",
+		},
 		cli.StringFlag{
 			EnvVar: "JIRA_BRANCH_HELPER_TEMPLATE",
 			Name:   argumentTemplate,
 			Usage:  "The template to use to generate the branch name",
 			Value:  defaultTemplate,
 		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TEMPLATE_FILE",
+			Name:   argumentTemplateFile,
+			Usage:  "A file containing the template, used instead of --template",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TEMPLATE_DIR",
+			Name:   argumentTemplateDir,
+			Usage:  "A directory of *.tmpl files the template can include",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CONFIG",
+			Name:   argumentConfig,
+			Usage:  "The configuration file listing Jira instances",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TRANSITION",
+			Name:   argumentTransition,
+			Usage: "A transition (or status) name or ID to move the " +
+				"issue through, e.g. \"In Progress\"",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_DRY_RUN",
+			Name:   argumentDryRun,
+			Usage: "Show what would change in Jira, or which branches " +
+				"prune would delete, without changing anything",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_ASSIGN_SELF",
+			Name:   argumentAssignSelf,
+			Usage:  "Assign the issue to yourself",
+		},
+		cli.BoolFlag{
+			Name: argumentForceAssign,
+			Usage: "With --assign-self, take the issue even if it is " +
+				"assigned to someone else",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_LINK_BRANCH",
+			Name:   argumentLinkBranch,
+			Usage:  "Add a link to the branch on the issue",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_COMMENT_BRANCH",
+			Name:   argumentCommentBranch,
+			Usage:  "Comment on the issue with the branch name",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_REPOSITORY_URL",
+			Name:   argumentRepositoryURL,
+			Usage: "The repository to mention in links and comments " +
+				"(default: the web URL of the origin remote)",
+		},
+		cli.DurationFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CONNECT_TIMEOUT",
+			Name:   argumentConnectTimeout,
+			Usage:  "How long to wait to connect to Jira",
+			Value:  branchhelper.DefaultHTTPClientOptions().ConnectTimeout,
+		},
+		cli.DurationFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TIMEOUT",
+			Name:   argumentTimeout,
+			Usage:  "How long each request to Jira can take, including retries",
+			Value:  branchhelper.DefaultHTTPClientOptions().Timeout,
+		},
+		cli.IntFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_RETRIES",
+			Name:   argumentRetries,
+			Usage:  "How many times to retry when Jira is busy or failing",
+			Value:  branchhelper.DefaultHTTPClientOptions().MaxRetries,
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CA_FILE",
+			Name:   argumentCAFile,
+			Usage:  "A PEM file of certificate authorities to trust",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_INSECURE_SKIP_VERIFY",
+			Name:   argumentInsecureSkipVerify,
+			Usage:  "Don't check Jira's certificate, not recommended",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CLIENT_CERT",
+			Name:   argumentClientCert,
+			Usage:  "A PEM client certificate, for proxies that require one",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CLIENT_KEY",
+			Name:   argumentClientKey,
+			Usage: "The PEM private key of the client certificate " +
+				"(default: the key in the certificate file)",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_CLIENT_KEY_PASSPHRASE",
+			Name:   argumentClientKeyPassphrase,
+			Usage: "The passphrase of an encrypted client key, you will be " +
+				"asked for it if it isn't set",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TRACKER",
+			Name:   argumentTracker,
+			Usage: "The tracker the issue is from, \"jira\", \"github\", " +
+				"\"gitlab\" or \"linear\" (default: worked out from the URL)",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TRACKER_ENDPOINT",
+			Name:   argumentTrackerEndpoint,
+			Usage: "The API of a self hosted GitHub or GitLab, e.g. " +
+				"https://github.example.com/api/v3/",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_TRACKER_TOKEN",
+			Name:   argumentTrackerToken,
+			Usage:  "The API token for GitHub, GitLab or Linear",
+		},
+		cli.StringFlag{
+			Name: argumentRecord,
+			Usage: "Record the requests to Jira and its responses to a " +
+				"file, without credentials, to reproduce problems elsewhere",
+		},
+		cli.StringFlag{
+			Name:  argumentReplay,
+			Usage: "Answer requests to Jira from a file made with --record",
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_UNIQUE",
+			Name:   argumentUnique,
+			Usage:  "Add a suffix to the branch name if the branch already exists",
+		},
+		cli.StringFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_UNIQUE_SUFFIX",
+			Name:   argumentUniqueSuffix,
+			Usage: "The template for the suffix added with --unique, " +
+				"using .Number and .Initials",
+			Value: branchhelper.DefaultBranchSuffix,
+		},
+		cli.BoolFlag{
+			EnvVar: "JIRA_BRANCH_HELPER_REUSE",
+			Name:   argumentReuse,
+			Usage:  "Use the existing branch if the branch already exists",
+		},
 	}
 	app.Action = action
+	app.Commands = []cli.Command{
+		{
+			Name:  "template",
+			Usage: "Work with branch templates",
+			Subcommands: []cli.Command{
+				templateCheckCommand(),
+			},
+		},
+		serveCommand(),
+		verifyCommand(),
+		pruneCommand(),
+	}
 	app.EnableBashCompletion = true
 
 	if err := app.Run(os.Args); err != nil {
@@ -160,6 +535,21 @@ func main() {
 	}
 }
 
+// jiraSettings are how to reach and authenticate with a Jira instance, and
+// the template to use with it
+type jiraSettings struct {
+	endpoint          string
+	basicAuthUsername string
+	basicAuthPassword string
+	username          string
+	password          string
+	template          string
+	templateFile      string
+	templateDir       string
+	transition        string
+	apiVersion        string
+}
+
 func action(c *cli.Context) error {
 	if c.NArg() != 1 {
 		return cli.NewExitError(
@@ -169,14 +559,31 @@ func action(c *cli.Context) error {
 		)
 	}
 
-	var endpointURL string
 	rawIssueID := c.Args().Get(0)
 	issueURL, err := url.Parse(rawIssueID)
 	issueStrategy := branchhelper.MakeIssueStrategy(issueURL)
 
-	if c.String(argumentJiraEndpoint) == "" {
-		endpointURL = branchhelper.GuessEndpointURL(issueURL)
-		if endpointURL == "" {
+	conf, err := loadConfig(c.GlobalString(argumentConfig))
+	if err != nil {
+		return cli.NewExitError(
+			err.Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	settings := resolveSettings(c, conf, rawIssueID)
+
+	if err := checkAPIVersion(settings.apiVersion); err != nil {
+		return err
+	}
+
+	if kind := trackerKind(c, issueURL); kind != branchhelper.TrackerJira {
+		return trackerAction(c, conf, settings, kind, rawIssueID)
+	}
+
+	if settings.endpoint == "" {
+		settings.endpoint = branchhelper.GuessEndpointURL(issueURL)
+		if settings.endpoint == "" {
 			return cli.NewExitError(
 				"you must provide a Jira URL via Flag or "+
 					"environment variable or a full issue url",
@@ -184,29 +591,19 @@ func action(c *cli.Context) error {
 			)
 		}
 	} else {
-		endpointURL = c.String(argumentJiraEndpoint)
-		endpointURL = normaliseEndpointURL(endpointURL)
-	}
-
-	jiraClient, err := jira.NewClient(nil, endpointURL)
-
-	if err != nil {
-		return cli.NewExitError(
-			errors.Wrap(
-				err,
-				"initialising jira client failed",
-			).Error(),
-			errorExitCodeJiraInitFailure,
-		)
+		settings.endpoint = normaliseEndpointURL(settings.endpoint)
 	}
 
-	if err := addSessionCookie(c, jiraClient); err != nil {
-		return err
+	jiraClient, exitErr := newJiraClient(c, settings)
+	if exitErr != nil {
+		return exitErr
 	}
 
-	addBasicAuth(c, jiraClient)
-
-	issueFormatter := branchhelper.NewJira(jiraClient)
+	issueFormatter := branchhelper.NewJiraWithAPIVersion(
+		jiraClient,
+		settings.apiVersion,
+	)
+	issueFormatter.Config = conf.TemplateConfig
 	issueID, err := issueStrategy.GetIssue(rawIssueID)
 
 	if err != nil {
@@ -216,8 +613,7 @@ func action(c *cli.Context) error {
 		)
 	}
 
-	template := c.String(argumentTemplate)
-	branchName, err := formatIssue(issueFormatter, template, issueID)
+	templ, err := parseTemplate(issueFormatter, settings)
 
 	if err != nil {
 		return cli.NewExitError(
@@ -226,6 +622,41 @@ func action(c *cli.Context) error {
 		)
 	}
 
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	branchName, err := issueFormatter.FormatIssueTemplateContext(
+		ctx,
+		issueID,
+		templ,
+	)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(
+				interrupted(ctx, err),
+				"failed to build branch name",
+			).Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	branchName, exitErr = avoidCollision(c, conf, branchName)
+	if exitErr != nil {
+		return exitErr
+	}
+
+	if err := updateIssue(
+		ctx,
+		c,
+		settings,
+		jiraClient,
+		issueID,
+		branchName,
+	); err != nil {
+		return err
+	}
+
 	if _, err := os.Stdout.WriteString(branchName + "\n"); err != nil {
 		wrappedErr := errors.Wrap(
 			err,
@@ -240,11 +671,210 @@ func action(c *cli.Context) error {
 
 	return nil
 }
-func addSessionCookie(c *cli.Context, jiraClient *jira.Client) *cli.ExitError {
-	if c.String(argumentJiraCookieUsername) != "" {
+
+// newJiraClient an authenticated client for the Jira in the settings
+func newJiraClient(
+	c *cli.Context,
+	settings jiraSettings,
+) (*jira.Client, *cli.ExitError) {
+	return newInstrumentedJiraClient(c, settings, nil)
+}
+
+// newInstrumentedJiraClient an authenticated client for the Jira in the
+// settings, counting every request it makes in the metrics
+func newInstrumentedJiraClient(
+	c *cli.Context,
+	settings jiraSettings,
+	metrics *branchhelper.Metrics,
+) (*jira.Client, *cli.ExitError) {
+	httpClient, err := newHTTPClient(c, metrics)
+
+	if err != nil {
+		return nil, cli.NewExitError(
+			errors.Wrap(err, "initialising http client failed").Error(),
+			errorExitCodeJiraInitFailure,
+		)
+	}
+
+	jiraClient, err := jira.NewClient(httpClient, settings.endpoint)
+
+	if err != nil {
+		return nil, cli.NewExitError(
+			errors.Wrap(
+				err,
+				"initialising jira client failed",
+			).Error(),
+			errorExitCodeJiraInitFailure,
+		)
+	}
+
+	if err := addSessionCookie(settings, jiraClient); err != nil {
+		return nil, err
+	}
+
+	addBasicAuth(settings, jiraClient)
+
+	return jiraClient, nil
+}
+
+// resolveSettings works out which Jira to talk to. An endpoint given as a flag
+// always wins, otherwise the instance from the configuration file that owns
+// the issue is used, with any flags given overriding its settings
+func resolveSettings(
+	c *cli.Context,
+	conf config,
+	rawIssueID string,
+) jiraSettings {
+	settings := jiraSettings{
+		endpoint:          c.GlobalString(argumentJiraEndpoint),
+		basicAuthUsername: c.GlobalString(argumentJiraBasicUsername),
+		basicAuthPassword: c.GlobalString(argumentJiraBasicPassword),
+		username:          c.GlobalString(argumentJiraCookieUsername),
+		password:          c.GlobalString(argumentJiraCookiePassword),
+		template:          c.GlobalString(argumentTemplate),
+		templateFile:      c.GlobalString(argumentTemplateFile),
+		templateDir:       c.GlobalString(argumentTemplateDir),
+		transition:        c.GlobalString(argumentTransition),
+		apiVersion:        c.GlobalString(argumentJiraAPIVersion),
+	}
+
+	if settings.transition == "" {
+		settings.transition = conf.Transition
+	}
+
+	if settings.endpoint != "" {
+		return settings
+	}
+
+	instance := conf.Instances.Select(rawIssueID)
+	if instance == nil {
+		return settings
+	}
+
+	settings.endpoint = instance.Endpoint
+
+	if settings.basicAuthUsername == "" {
+		settings.basicAuthUsername = instance.BasicAuthUsername
+		settings.basicAuthPassword = instance.BasicAuthPassword
+	}
+
+	if settings.username == "" {
+		settings.username = instance.Username
+		settings.password = instance.Password
+	}
+
+	if !c.GlobalIsSet(argumentTemplate) && instance.Template != "" {
+		settings.template = instance.Template
+	}
+
+	if !c.GlobalIsSet(argumentTransition) && instance.Transition != "" {
+		settings.transition = instance.Transition
+	}
+
+	if settings.apiVersion == "" {
+		settings.apiVersion = instance.APIVersion
+	}
+
+	return settings
+}
+
+// checkAPIVersion only allow the versions of the Jira API we know how to use
+func checkAPIVersion(version string) *cli.ExitError {
+	switch version {
+	case "", branchhelper.APIVersion2, branchhelper.APIVersion3:
+		return nil
+	}
+
+	return cli.NewExitError(
+		fmt.Sprintf(
+			"unknown jira api version %q, use %q or %q",
+			version,
+			branchhelper.APIVersion2,
+			branchhelper.APIVersion3,
+		),
+		errorExitCodeConfigFailure,
+	)
+}
+
+// newHTTPClient a client for talking to Jira that won't hang forever, timing
+// each attempt at a request in the metrics if there are any
+func newHTTPClient(
+	c *cli.Context,
+	metrics *branchhelper.Metrics,
+) (*http.Client, error) {
+	recordPath := c.GlobalString(argumentRecord)
+	replayPath := c.GlobalString(argumentReplay)
+
+	if recordPath != "" && replayPath != "" {
+		return nil, errors.New("can't record and replay at the same time")
+	}
+
+	if replayPath != "" {
+		recording, err := branchhelper.LoadRecording(replayPath)
+		if err != nil {
+			return nil, err
+		}
+
+		var transport http.RoundTripper = &branchhelper.ReplayTransport{
+			Recording: recording,
+		}
+		if metrics != nil {
+			transport = &branchhelper.InstrumentedTransport{
+				Transport: transport,
+				Metrics:   metrics,
+			}
+		}
+
+		return &http.Client{Transport: transport}, nil
+	}
+
+	options := branchhelper.DefaultHTTPClientOptions()
+	options.ConnectTimeout = c.GlobalDuration(argumentConnectTimeout)
+	options.Timeout = c.GlobalDuration(argumentTimeout)
+	options.MaxRetries = c.GlobalInt(argumentRetries)
+	options.CAFile = c.GlobalString(argumentCAFile)
+	options.InsecureSkipVerify = c.GlobalBool(argumentInsecureSkipVerify)
+	options.ClientCertFile = c.GlobalString(argumentClientCert)
+	options.ClientKeyFile = c.GlobalString(argumentClientKey)
+	options.ClientKeyPassphrase = clientKeyPassphrase(c)
+	options.Metrics = metrics
+
+	client, err := branchhelper.NewHTTPClient(options)
+	if err != nil || recordPath == "" {
+		return client, err
+	}
+
+	client.Transport = &branchhelper.RecordingTransport{
+		Transport: client.Transport,
+		Path:      recordPath,
+	}
+
+	return client, nil
+}
+
+// clientKeyPassphrase the passphrase for the client key from the flags, or
+// asked for on the terminal if the key turns out to be encrypted
+func clientKeyPassphrase(c *cli.Context) func() ([]byte, error) {
+	if passphrase := c.GlobalString(argumentClientKeyPassphrase); passphrase != "" {
+		return func() ([]byte, error) {
+			return []byte(passphrase), nil
+		}
+	}
+
+	keyFile := c.GlobalString(argumentClientKey)
+
+	if keyFile == "" {
+		keyFile = c.GlobalString(argumentClientCert)
+	}
+
+	return promptPassphrase("Passphrase for " + keyFile + ": ")
+}
+
+func addSessionCookie(settings jiraSettings, jiraClient *jira.Client) *cli.ExitError {
+	if settings.username != "" {
 		if _, err := jiraClient.Authentication.AcquireSessionCookie(
-			c.String(argumentJiraCookieUsername),
-			c.String(argumentJiraCookiePassword),
+			settings.username,
+			settings.password,
 		); err != nil {
 			wrappedErr := errors.Wrap(
 				err,
@@ -260,11 +890,11 @@ func addSessionCookie(c *cli.Context, jiraClient *jira.Client) *cli.ExitError {
 
 	return nil
 }
-func addBasicAuth(c *cli.Context, jiraClient *jira.Client) {
-	if c.String(argumentJiraBasicUsername) != "" {
+func addBasicAuth(settings jiraSettings, jiraClient *jira.Client) {
+	if settings.basicAuthUsername != "" {
 		jiraClient.Authentication.SetBasicAuth(
-			c.String(argumentJiraBasicUsername),
-			c.String(argumentJiraBasicPassword),
+			settings.basicAuthUsername,
+			settings.basicAuthPassword,
 		)
 	}
 }
@@ -276,18 +906,33 @@ func normaliseEndpointURL(endpointURL string) string {
 	return endpointURL
 }
 
-func formatIssue(
+func parseTemplate(
 	issueFormatter *branchhelper.Jira,
-	template string,
-	issueID string,
-) (string, error) {
+	settings jiraSettings,
+) (*template.Template, error) {
+	includePatterns := templateIncludes(settings)
+
+	if settings.templateFile != "" {
+		return issueFormatter.ParseTemplateFile(
+			settings.templateFile,
+			includePatterns...,
+		)
+	}
 
-	if template == "" {
-		template = defaultTemplate
+	rawTempl := settings.template
+	if rawTempl == "" {
+		rawTempl = defaultTemplate
 	}
-	branchName, err := issueFormatter.FormatIssue(
-		issueID,
-		template,
-	)
-	return branchName, err
+
+	return issueFormatter.ParseTemplate(rawTempl, includePatterns...)
+}
+
+// templateIncludes the patterns of the files with templates that the branch
+// template can use
+func templateIncludes(settings jiraSettings) []string {
+	if settings.templateDir == "" {
+		return []string{}
+	}
+
+	return []string{filepath.Join(settings.templateDir, "*.tmpl")}
 }
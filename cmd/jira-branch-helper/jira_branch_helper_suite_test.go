@@ -0,0 +1,55 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// binaryPath is where the command is built for the tests to run
+var binaryPath string
+
+// buildDir holds the built command until the suite is done
+var buildDir string
+
+func TestJiraBranchHelperCommand(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Command Suite")
+}
+
+var _ = BeforeSuite(func() {
+	var err error
+	buildDir, err = ioutil.TempDir("", "jira-branch-helper-build")
+	Expect(err).To(BeNil())
+
+	binaryPath = filepath.Join(buildDir, "jira-branch-helper")
+	out, err := exec.Command("go", "build", "-o", binaryPath, ".").CombinedOutput()
+	Expect(err).To(BeNil(), string(out))
+})
+
+var _ = AfterSuite(func() {
+	Expect(os.RemoveAll(buildDir)).To(Succeed())
+})
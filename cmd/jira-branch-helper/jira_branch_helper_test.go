@@ -0,0 +1,357 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper/branchhelpertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// result what running the command wrote to stdout, and how it exited
+type result struct {
+	Out      string
+	ExitCode int
+}
+
+// doneStatus a status in the done category, as Jira returns it
+var doneStatus = map[string]interface{}{
+	"name":           "Done",
+	"statusCategory": map[string]interface{}{"key": "done"},
+}
+
+// todoStatus a status in the to do category, as Jira returns it
+var todoStatus = map[string]interface{}{
+	"name":           "To Do",
+	"statusCategory": map[string]interface{}{"key": "new"},
+}
+
+var _ = Describe("jira-branch-helper", func() {
+	var server *branchhelpertest.Server
+	var dir string
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(
+			os.Environ(),
+			"HOME="+dir,
+			"GIT_AUTHOR_NAME=Test User",
+			"GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test User",
+			"GIT_COMMITTER_EMAIL=test@example.com",
+		)
+
+		out, err := cmd.CombinedOutput()
+		Expect(err).To(BeNil(), string(out))
+
+		return string(out)
+	}
+
+	run := func(args ...string) result {
+		out := &bytes.Buffer{}
+		cmd := exec.Command(binaryPath, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "HOME="+dir)
+		cmd.Stdout = io.MultiWriter(out, GinkgoWriter)
+		cmd.Stderr = GinkgoWriter
+
+		err := cmd.Run()
+		if _, exited := err.(*exec.ExitError); !exited {
+			Expect(err).To(BeNil())
+		}
+
+		return result{
+			Out:      out.String(),
+			ExitCode: cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus(),
+		}
+	}
+
+	BeforeEach(func() {
+		server = branchhelpertest.NewServer()
+		server.AddIssue("TST-123", map[string]interface{}{
+			"summary": "Implement the login page",
+			"status":  todoStatus,
+		})
+
+		var err error
+		dir, err = ioutil.TempDir("", "jira-branch-helper")
+		Expect(err).To(BeNil())
+
+		git("init", "--quiet")
+		git("symbolic-ref", "HEAD", "refs/heads/master")
+		git("commit", "--quiet", "--allow-empty", "--message", "Initial commit")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Names branches after issues", func() {
+		session := run("--jira-endpoint", server.URL, "TST-123")
+
+		Expect(session.ExitCode).To(Equal(0))
+		Expect(session.Out).To(
+			Equal("tst-123-implement-the-login-page\n"),
+		)
+	})
+	It("Reads the endpoint from the configuration file", func() {
+		config := filepath.Join(dir, "config.json")
+		Expect(ioutil.WriteFile(
+			config,
+			[]byte(`{"instances": [{"endpoint": "`+server.URL+`", "projects": ["TST"]}]}`),
+			0600,
+		)).To(Succeed())
+
+		session := run("--config", config, "TST-123")
+
+		Expect(session.ExitCode).To(Equal(0))
+		Expect(session.Out).To(
+			Equal("tst-123-implement-the-login-page\n"),
+		)
+	})
+	It("Fails for issues Jira doesn't have", func() {
+		session := run("--jira-endpoint", server.URL, "TST-999")
+
+		Expect(session.ExitCode).To(Equal(8))
+		Expect(session.Out).To(BeEmpty())
+	})
+
+	Context("Starting work", func() {
+		BeforeEach(func() {
+			transition := branchhelper.Transition{ID: "21", Name: "Start Progress"}
+			transition.To.Name = "In Progress"
+			server.SetTransitions("TST-123", transition)
+		})
+
+		It("Assigns and transitions the issue", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"--assign-self",
+				"--transition", "In Progress",
+				"TST-123",
+			)
+
+			Expect(session.ExitCode).To(Equal(0))
+			Expect(server.Assignee("TST-123").Same(branchhelpertest.DefaultUser())).
+				To(BeTrue())
+			Expect(server.Status("TST-123")).To(Equal("In Progress"))
+		})
+		It("Leaves issues already assigned to someone else where they are", func() {
+			server.AddIssue("TST-123", map[string]interface{}{
+				"summary": "Implement the login page",
+				"status":  todoStatus,
+				"assignee": map[string]interface{}{
+					"name":        "someone",
+					"key":         "someone",
+					"accountId":   "5b10ac8d82e05b22cc7d4ef5",
+					"displayName": "Someone Else",
+				},
+			})
+
+			session := run(
+				"--jira-endpoint", server.URL,
+				"--assign-self",
+				"--transition", "In Progress",
+				"TST-123",
+			)
+
+			Expect(session.ExitCode).To(Equal(128))
+			Expect(session.Out).To(BeEmpty())
+			Expect(server.Status("TST-123")).To(Equal("To Do"))
+
+			for _, request := range server.Requests() {
+				Expect(request.Method + " " + request.Path).ToNot(
+					Equal("POST /rest/api/2/issue/TST-123/transitions"),
+				)
+			}
+		})
+	})
+
+	Context("serve", func() {
+		It("Refuses to log in with sessions, as they expire", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"--jira-username", "user",
+				"--jira-password", "secret",
+				"serve",
+			)
+
+			Expect(session.ExitCode).To(Equal(64))
+			Expect(server.Requests()).To(BeEmpty())
+		})
+	})
+
+	Context("verify", func() {
+		It("Passes branches for open issues", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "tst-123-implement-the-login-page",
+			)
+
+			Expect(session.ExitCode).To(Equal(0))
+		})
+		It("Fails branches for finished issues", func() {
+			server.AddIssue("TST-123", map[string]interface{}{
+				"summary": "Implement the login page",
+				"status":  doneStatus,
+			})
+
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "tst-123-implement-the-login-page",
+			)
+
+			Expect(session.ExitCode).To(Equal(20))
+		})
+		It("Fails branches for issues that don't exist", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "tst-999-missing",
+			)
+
+			Expect(session.ExitCode).To(Equal(17))
+		})
+		It("Fails branches for issues in other projects", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "--project", "OPS", "tst-123-implement-the-login-page",
+			)
+
+			Expect(session.ExitCode).To(Equal(18))
+		})
+		It("Fails branches that aren't named after the issue", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "--check-name", "tst-123-login",
+			)
+
+			Expect(session.ExitCode).To(Equal(24))
+		})
+		It("Fails when Jira does", func() {
+			server.Fail("issue/TST-123", http.StatusBadRequest, 0)
+
+			session := run(
+				"--jira-endpoint", server.URL,
+				"verify", "tst-123-implement-the-login-page",
+			)
+
+			Expect(session.ExitCode).To(Equal(4))
+		})
+		It("Fails branches without an issue key without asking Jira", func() {
+			session := run("--jira-endpoint", server.URL, "verify", "fix-typo")
+
+			Expect(session.ExitCode).To(Equal(16))
+			Expect(server.Requests()).To(BeEmpty())
+		})
+	})
+
+	Context("prune", func() {
+		BeforeEach(func() {
+			server.AddIssue("TST-124", map[string]interface{}{
+				"summary": "Log out",
+				"status":  doneStatus,
+			})
+			server.AddIssue("RELEASE-1", map[string]interface{}{
+				"summary": "Release",
+				"status":  doneStatus,
+			})
+
+			git("branch", "release-1.2")
+			git("branch", "tst-123-implement-the-login-page")
+			git("branch", "tst-124-log-out")
+		})
+
+		branches := func() []string {
+			return strings.Fields(
+				git("for-each-ref", "--format=%(refname:short)", "refs/heads"),
+			)
+		}
+
+		It("Deletes branches for finished issues", func() {
+			session := run("--jira-endpoint", server.URL, "prune", "--project", "TST")
+
+			Expect(session.ExitCode).To(Equal(0))
+			Expect(session.Out).To(
+				Equal("deleted tst-124-log-out (TST-124 is Done)\n"),
+			)
+			Expect(branches()).To(Equal([]string{
+				"master",
+				"release-1.2",
+				"tst-123-implement-the-login-page",
+			}))
+		})
+		It("Only lists them on a dry run", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"--dry-run",
+				"prune", "--project", "TST",
+			)
+
+			Expect(session.ExitCode).To(Equal(0))
+			Expect(session.Out).To(
+				Equal("would delete tst-124-log-out (TST-124 is Done)\n"),
+			)
+			Expect(branches()).To(HaveLen(4))
+		})
+		It("Uses the projects of the configured instances", func() {
+			config := filepath.Join(dir, "config.json")
+			Expect(ioutil.WriteFile(
+				config,
+				[]byte(`{"instances": [{"endpoint": "`+server.URL+`", "projects": ["TST"]}]}`),
+				0600,
+			)).To(Succeed())
+
+			session := run("--config", config, "--dry-run", "prune")
+
+			Expect(session.ExitCode).To(Equal(0))
+			Expect(session.Out).To(
+				Equal("would delete tst-124-log-out (TST-124 is Done)\n"),
+			)
+		})
+		It("Refuses to guess which names have issue keys", func() {
+			session := run("--jira-endpoint", server.URL, "prune")
+
+			Expect(session.ExitCode).To(Equal(64))
+			Expect(branches()).To(HaveLen(4))
+			Expect(server.Requests()).To(BeEmpty())
+		})
+		It("Fails when the branches can't be listed", func() {
+			session := run(
+				"--jira-endpoint", server.URL,
+				"prune", "--project", "TST", "--main-branch", "missing",
+			)
+
+			Expect(session.ExitCode).To(Equal(8))
+			Expect(branches()).To(HaveLen(4))
+		})
+	})
+})
@@ -0,0 +1,182 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// updateIssue make the changes to the issue that were asked for, now work on
+// it is starting. The issue is assigned before it is transitioned, so an
+// issue someone else is working on is left where it is
+func updateIssue(
+	ctx context.Context,
+	c *cli.Context,
+	settings jiraSettings,
+	jiraClient *jira.Client,
+	issueID string,
+	branchName string,
+) *cli.ExitError {
+	dryRun := c.GlobalBool(argumentDryRun)
+	client := branchhelper.RequestClientWithContext(ctx, jiraClient)
+
+	if c.GlobalBool(argumentAssignSelf) {
+		assigner := branchhelper.AssignClient{
+			Client:     client,
+			APIVersion: settings.apiVersion,
+		}
+		user, err := assigner.AssignSelf(
+			issueID,
+			c.GlobalBool(argumentForceAssign),
+			dryRun,
+		)
+
+		if err != nil {
+			return cli.NewExitError(
+				errors.Wrap(
+					interrupted(ctx, err),
+					"failed to assign issue",
+				).Error(),
+				errorExitCodeJiraUpdateFailure,
+			)
+		}
+
+		reportUpdate(dryRun, "assign %s to %s", issueID, user)
+	}
+
+	if settings.transition != "" {
+		transitions := branchhelper.TransitionsClient{
+			Client:     client,
+			APIVersion: settings.apiVersion,
+		}
+		transition, err := transitions.TransitionIssue(
+			issueID,
+			settings.transition,
+			dryRun,
+		)
+
+		if err != nil {
+			return cli.NewExitError(
+				errors.Wrap(
+					interrupted(ctx, err),
+					"failed to transition issue",
+				).Error(),
+				errorExitCodeJiraUpdateFailure,
+			)
+		}
+
+		reportUpdate(dryRun, "transition %s using %s", issueID, transition)
+	}
+
+	return recordBranch(ctx, c, settings, client, issueID, branchName)
+}
+
+// recordBranch link to or comment about the branch on the issue
+func recordBranch(
+	ctx context.Context,
+	c *cli.Context,
+	settings jiraSettings,
+	client branchhelper.RequestClient,
+	issueID string,
+	branchName string,
+) *cli.ExitError {
+	dryRun := c.GlobalBool(argumentDryRun)
+	links := branchhelper.LinksClient{
+		Client:     client,
+		APIVersion: settings.apiVersion,
+	}
+	branch := branchhelper.Branch{
+		Name:          branchName,
+		RepositoryURL: c.GlobalString(argumentRepositoryURL),
+	}
+
+	if !c.GlobalBool(argumentLinkBranch) && !c.GlobalBool(argumentCommentBranch) {
+		return nil
+	}
+
+	if branch.RepositoryURL == "" {
+		branch.RepositoryURL = branchhelper.RepositoryWebURL(originURL())
+	}
+
+	if c.GlobalBool(argumentLinkBranch) {
+		added, err := links.LinkBranch(issueID, branch, dryRun)
+
+		if err != nil {
+			return cli.NewExitError(
+				errors.Wrap(
+					interrupted(ctx, err),
+					"failed to link branch",
+				).Error(),
+				errorExitCodeJiraUpdateFailure,
+			)
+		}
+
+		if added {
+			reportUpdate(dryRun, "link %s to %s", issueID, branch.URL())
+		}
+	}
+
+	if c.GlobalBool(argumentCommentBranch) {
+		added, err := links.CommentBranch(issueID, branch, dryRun)
+
+		if err != nil {
+			return cli.NewExitError(
+				errors.Wrap(
+					interrupted(ctx, err),
+					"failed to comment on issue",
+				).Error(),
+				errorExitCodeJiraUpdateFailure,
+			)
+		}
+
+		if added {
+			reportUpdate(dryRun, "comment on %s %q", issueID, branch.Comment())
+		}
+	}
+
+	return nil
+}
+
+// originURL the URL of the origin remote of the repository we are in, if
+// there is one
+func originURL() string {
+	out, err := exec.Command("git", "config", "--get", "remote.origin.url").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// reportUpdate tell the user about changes on a dry run, out of the way of
+// the branch name
+func reportUpdate(dryRun bool, format string, args ...interface{}) {
+	if dryRun {
+		fmt.Fprintf(os.Stderr, "would "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,67 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// promptPassphrase ask for a passphrase on the terminal, without echoing it
+func promptPassphrase(prompt string) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "no terminal to ask for the passphrase on")
+		}
+
+		defer func() { _ = tty.Close() }()
+
+		if _, err := fmt.Fprint(tty, prompt); err != nil {
+			return nil, errors.Wrap(err, "failed to ask for the passphrase")
+		}
+
+		if err := stty(tty, "-echo"); err == nil {
+			defer func() {
+				_ = stty(tty, "echo")
+				_, _ = fmt.Fprintln(tty)
+			}()
+		}
+
+		line, err := bufio.NewReader(tty).ReadString('\n')
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the passphrase")
+		}
+
+		return []byte(strings.TrimRight(line, "\r\n")), nil
+	}
+}
+
+// stty change the settings of the terminal
+func stty(tty *os.File, args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+
+	return cmd.Run()
+}
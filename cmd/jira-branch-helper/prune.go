@@ -0,0 +1,302 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// Exit codes for the git commands prune runs failing. Exit statuses only have
+// eight bits, and the errors use them all, so these are flags prune never
+// exits with otherwise
+const (
+	pruneExitCodeListFailure   = errorExitCodeBranchNameBuildFailure
+	pruneExitCodeDeleteFailure = errorExitCodeCouldNotParseIssue
+)
+
+const (
+	// argumentRemote is the option to prune remote branches as well as local
+	// ones
+	argumentRemote = "remote"
+	// argumentMainBranch is the option to set the branch that branches must
+	// be merged into to be pruned
+	argumentMainBranch = "main-branch"
+)
+
+func pruneCommand() cli.Command {
+	return cli.Command{
+		Name:  "prune",
+		Usage: "Delete branches for finished Jira issues",
+		Description: "Deletes the branches that are merged into the main " +
+			"branch, whose issues are done (or in one of the statuses " +
+			"given with --status). Only branches for issues in the " +
+			"projects of the configured instances, or given with " +
+			"--project, are deleted, as names like release-1.2 look like " +
+			"they have issue keys. With --remote branches on remotes are " +
+			"deleted too. With --dry-run the branches are listed rather " +
+			"than deleted. The main branch is the default branch of " +
+			"origin, or master if origin doesn't have one. Exits with 8 " +
+			"if the branches can't be listed, and 16 if any can't be " +
+			"deleted",
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_PRUNE_STATUS",
+				Name:   argumentStatus,
+				Usage: "A status of finished issues, can be given more " +
+					"than once (default: statuses that are done)",
+			},
+			cli.StringSliceFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_PRUNE_PROJECT",
+				Name:   argumentProject,
+				Usage: "A project to delete branches for, can be given " +
+					"more than once (default: the projects of the " +
+					"configured instances)",
+			},
+			cli.BoolFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_PRUNE_REMOTE",
+				Name:   argumentRemote,
+				Usage:  "Delete branches on remotes too",
+			},
+			cli.StringFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_MAIN_BRANCH",
+				Name:   argumentMainBranch,
+				Usage: "The branch that branches must be merged into " +
+					"(default: the default branch of origin, or master)",
+			},
+		},
+		Action: pruneAction,
+	}
+}
+
+func pruneAction(c *cli.Context) error {
+	if c.NArg() != 0 {
+		return cli.NewExitError(
+			"incorrect number of arguments, see "+
+				"`jira-branch-helper prune --help` for full usage information",
+			errorExitCodeIncorrectNumberOfArguments,
+		)
+	}
+
+	conf, err := loadConfig(c.GlobalString(argumentConfig))
+	if err != nil {
+		return cli.NewExitError(
+			err.Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	policy := branchhelper.PrunePolicy{
+		MainBranch: c.String(argumentMainBranch),
+		Remote:     c.Bool(argumentRemote),
+		Projects:   pruneProjects(c, conf),
+		Statuses:   c.StringSlice(argumentStatus),
+	}
+
+	if len(policy.Projects) == 0 {
+		return cli.NewExitError(
+			"no projects to delete branches for, give them with --project "+
+				"or configure instances with projects",
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	if policy.MainBranch == "" {
+		policy.MainBranch = defaultMainBranch()
+	}
+
+	policy.CheckedOut, _ = currentBranch()
+	branches, err := mergedBranches(policy.MainBranch)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to list branches").Error(),
+			pruneExitCodeListFailure,
+		)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	statuses := map[string]jira.Status{}
+
+	for _, keys := range keysByJira(c, conf, policy.BranchIssueKeys(branches)) {
+		settings, exitErr := issueSettings(c, conf, keys[0])
+		if exitErr != nil {
+			return exitErr
+		}
+
+		jiraClient, exitErr := newJiraClient(c, settings)
+		if exitErr != nil {
+			return exitErr
+		}
+
+		search := branchhelper.SearchClient{
+			Client:     branchhelper.RequestClientWithContext(ctx, jiraClient),
+			APIVersion: settings.apiVersion,
+		}
+		found, err := search.IssueStatuses(keys)
+
+		if err != nil {
+			return cli.NewExitError(
+				errors.Wrap(
+					interrupted(ctx, err),
+					"failed to get the statuses of issues",
+				).Error(),
+				errorExitCodeJiraInitFailure,
+			)
+		}
+
+		for key, status := range found {
+			statuses[key] = status
+		}
+	}
+
+	return pruneBranches(
+		c.GlobalBool(argumentDryRun),
+		policy.StaleBranches(branches, statuses),
+	)
+}
+
+// pruneProjects the projects given, or the projects of the configured
+// instances
+func pruneProjects(c *cli.Context, conf config) []string {
+	if projects := c.StringSlice(argumentProject); len(projects) > 0 {
+		return projects
+	}
+
+	projects := []string{}
+	for _, instance := range conf.Instances {
+		projects = append(projects, instance.Projects...)
+	}
+
+	return projects
+}
+
+// keysByJira the keys of issues, grouped by the endpoint of the Jira they are
+// in, so each Jira is searched once
+func keysByJira(
+	c *cli.Context,
+	conf config,
+	keys []string,
+) map[string][]string {
+	grouped := map[string][]string{}
+
+	for _, key := range keys {
+		endpoint := resolveSettings(c, conf, key).endpoint
+		grouped[endpoint] = append(grouped[endpoint], key)
+	}
+
+	return grouped
+}
+
+// pruneBranches delete the branches, or list them on a dry run. Every branch
+// is tried, even if deleting one fails
+func pruneBranches(dryRun bool, stale []branchhelper.StaleBranch) error {
+	failed := []string{}
+
+	for _, branch := range stale {
+		description := fmt.Sprintf(
+			"%s (%s is %s)",
+			branch.Branch,
+			branch.Key,
+			branch.Status,
+		)
+
+		if dryRun {
+			fmt.Fprintln(os.Stdout, "would delete "+description)
+			continue
+		}
+
+		if err := deleteBranch(branch.Branch); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to delete %s: %s\n", branch.Branch, err)
+			failed = append(failed, branch.Branch.String())
+
+			continue
+		}
+
+		fmt.Fprintln(os.Stdout, "deleted "+description)
+	}
+
+	if len(failed) > 0 {
+		return cli.NewExitError(
+			"failed to delete "+strings.Join(failed, ", "),
+			pruneExitCodeDeleteFailure,
+		)
+	}
+
+	return nil
+}
+
+// defaultMainBranch the default branch of origin, or master if it doesn't
+// have one
+func defaultMainBranch() string {
+	out, err := exec.Command(
+		"git",
+		"symbolic-ref",
+		"--short",
+		"refs/remotes/origin/HEAD",
+	).Output()
+
+	if err != nil {
+		return "master"
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// mergedBranches the local branches, and branches on remotes, merged into the
+// main branch
+func mergedBranches(mainBranch string) ([]branchhelper.ExistingBranch, error) {
+	out, err := exec.Command(
+		"git",
+		"for-each-ref",
+		"--format=%(refname)",
+		"--merged="+mainBranch,
+		"refs/heads",
+		"refs/remotes",
+	).Output()
+
+	if err != nil {
+		return nil, errors.Errorf("can't find branches merged into %s", mainBranch)
+	}
+
+	return branchhelper.ParseBranchRefs(strings.Split(string(out), "\n")), nil
+}
+
+// deleteBranch delete a local branch, or a branch on a remote
+func deleteBranch(branch branchhelper.ExistingBranch) error {
+	cmd := exec.Command("git", "branch", "-D", branch.Name)
+	if branch.Remote != "" {
+		cmd = exec.Command("git", "push", branch.Remote, "--delete", branch.Name)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrap(err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
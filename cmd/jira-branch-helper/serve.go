@@ -0,0 +1,288 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const (
+	// argumentListen is the option to set the address to serve branch names
+	// on
+	argumentListen = "listen"
+	// argumentCacheTTL is the option to set how long issues are remembered
+	// for when serving
+	argumentCacheTTL = "cache-ttl"
+	// argumentShutdownTimeout is the option to set how long to wait for
+	// requests to finish when stopping
+	argumentShutdownTimeout = "shutdown-timeout"
+	// argumentWebhookSecret is the option to set the secret Jira webhooks
+	// are sent with
+	argumentWebhookSecret = "webhook-secret"
+	// argumentAllowTemplates is the option to let callers give their own
+	// templates with ?template=
+	argumentAllowTemplates = "allow-templates"
+)
+
+func serveCommand() cli.Command {
+	return cli.Command{
+		Name:  "serve",
+		Usage: "Serve branch names over HTTP",
+		Description: "Runs an HTTP server that answers GET /branch/{key} " +
+			"with the branch name for an issue as JSON. With " +
+			"--allow-templates another template can be given with " +
+			"?template=, which can read any field of any issue the " +
+			"server can see. /healthz is ok " +
+			"while the server is running, /readyz while it can reach " +
+			"Jira, and Prometheus metrics are at /metrics. The Jira is " +
+			"the one given by --jira-endpoint, or the only instance in " +
+			"the configuration file. Ctrl-C (or SIGTERM) stops the server " +
+			"once the requests it is answering are done. " +
+			"With --webhook-secret, Jira webhooks for created and updated " +
+			"issues can be sent to POST /webhook, signed with the secret " +
+			"or with it as ?secret=. With --comment-branch the branch " +
+			"name is commented on the issue. Sessions from --jira-username " +
+			"expire, so the server needs basic auth to log in to Jira",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_LISTEN",
+				Name:   argumentListen,
+				Usage:  "The address to listen on",
+				Value:  "127.0.0.1:8080",
+			},
+			cli.DurationFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_CACHE_TTL",
+				Name:   argumentCacheTTL,
+				Usage:  "How long to remember issues for",
+				Value:  branchhelper.DefaultIssueCacheTTL,
+			},
+			cli.DurationFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_SHUTDOWN_TIMEOUT",
+				Name:   argumentShutdownTimeout,
+				Usage:  "How long to wait for requests to finish when stopping",
+				Value:  10 * time.Second,
+			},
+			cli.StringFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_WEBHOOK_SECRET",
+				Name:   argumentWebhookSecret,
+				Usage:  "The secret Jira webhooks are sent with",
+			},
+			cli.BoolFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_ALLOW_TEMPLATES",
+				Name:   argumentAllowTemplates,
+				Usage:  "Let callers give their own templates with ?template=",
+			},
+		},
+		Action: serveAction,
+	}
+}
+
+func serveAction(c *cli.Context) error {
+	if c.NArg() != 0 {
+		return cli.NewExitError(
+			"incorrect number of arguments, see "+
+				"`jira-branch-helper serve --help` for full usage information",
+			errorExitCodeIncorrectNumberOfArguments,
+		)
+	}
+
+	conf, err := loadConfig(c.GlobalString(argumentConfig))
+	if err != nil {
+		return cli.NewExitError(
+			err.Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	settings := resolveSettings(c, conf, "")
+
+	// With no endpoint given, a configuration with one instance is used as
+	// if its issues were asked about
+	if settings.endpoint == "" && len(conf.Instances) == 1 {
+		settings = resolveSettings(c, conf, conf.Instances[0].Endpoint)
+	}
+
+	if settings.endpoint == "" {
+		return cli.NewExitError(
+			"you must provide a Jira URL via Flag or environment variable, "+
+				"or configure a single instance",
+			errorExitCodeNoEndpointURL,
+		)
+	}
+
+	settings.endpoint = normaliseEndpointURL(settings.endpoint)
+
+	if err := checkAPIVersion(settings.apiVersion); err != nil {
+		return err
+	}
+
+	// Sessions are only acquired once, so would stop working when they
+	// expire while serving
+	if settings.username != "" {
+		return cli.NewExitError(
+			"serve can't log in with --jira-username as sessions expire, "+
+				"use --jira-basic-auth-username instead",
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	metrics := branchhelper.NewMetrics()
+	jiraClient, exitErr := newInstrumentedJiraClient(c, settings, metrics)
+	if exitErr != nil {
+		return exitErr
+	}
+
+	issueFormatter := branchhelper.NewJiraWithAPIVersion(
+		jiraClient,
+		settings.apiVersion,
+	)
+	issueFormatter.Config = conf.TemplateConfig
+	issueFormatter.Client = &branchhelper.CachedIssueClient{
+		Client:  issueFormatter.Client,
+		TTL:     c.Duration(argumentCacheTTL),
+		Metrics: metrics,
+	}
+
+	templ, err := parseTemplate(issueFormatter, settings)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to parse branch template").Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	branchServer := issueFormatter.NewBranchServer(templ)
+	branchServer.Includes = templateIncludes(settings)
+	branchServer.AllowTemplates = c.Bool(argumentAllowTemplates)
+	branchServer.Ready = jiraReady(jiraClient, settings.apiVersion)
+	branchServer.WebhookSecret = c.String(argumentWebhookSecret)
+	branchServer.Metrics = metrics
+
+	if c.GlobalBool(argumentCommentBranch) {
+		branchServer.CommentBranch = commentBranch(c, jiraClient, settings)
+	}
+
+	return serve(c, branchServer)
+}
+
+// serve answer requests until interrupted, then wait for the requests being
+// answered to finish
+func serve(c *cli.Context, branchServer *branchhelper.BranchServer) error {
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	server := &http.Server{
+		Addr:    c.String(argumentListen),
+		Handler: branchServer,
+	}
+	failed := make(chan error, 1)
+
+	go func() {
+		failed <- server.ListenAndServe()
+	}()
+
+	log.Printf("serving branch names on %s", server.Addr)
+
+	select {
+	case err := <-failed:
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to serve").Error(),
+			errorExitCodeConfigFailure,
+		)
+	case <-ctx.Done():
+	}
+
+	log.Print("stopping, waiting for requests to finish")
+	branchServer.Drain()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(
+		context.Background(),
+		c.Duration(argumentShutdownTimeout),
+	)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to stop cleanly").Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	return nil
+}
+
+// jiraReady checks Jira can be reached, and that we can log in to it
+func jiraReady(
+	jiraClient *jira.Client,
+	apiVersion string,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := branchhelper.AssignClient{
+			Client:     branchhelper.RequestClientWithContext(ctx, jiraClient),
+			APIVersion: apiVersion,
+		}.Myself()
+
+		if err != nil {
+			return errors.Wrap(err, "failed to reach jira")
+		}
+
+		return nil
+	}
+}
+
+// commentBranch comments the branch names built for webhooks on their issues
+func commentBranch(
+	c *cli.Context,
+	jiraClient *jira.Client,
+	settings jiraSettings,
+) branchhelper.CommentBranchFunc {
+	dryRun := c.GlobalBool(argumentDryRun)
+	repositoryURL := c.GlobalString(argumentRepositoryURL)
+
+	return func(
+		ctx context.Context,
+		issueID string,
+		branchName string,
+	) (bool, error) {
+		links := branchhelper.LinksClient{
+			Client:     branchhelper.RequestClientWithContext(ctx, jiraClient),
+			APIVersion: settings.apiVersion,
+		}
+		branch := branchhelper.Branch{
+			Name:          branchName,
+			RepositoryURL: repositoryURL,
+		}
+
+		added, err := links.CommentBranch(issueID, branch, dryRun)
+		if added {
+			reportUpdate(dryRun, "comment on %s %q", issueID, branch.Comment())
+		}
+
+		return added, err
+	}
+}
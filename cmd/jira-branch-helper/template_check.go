@@ -0,0 +1,141 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// argumentFixture is the option to set a JSON Jira issue to check the
+// template against
+const argumentFixture = "fixture"
+
+func templateCheckCommand() cli.Command {
+	return cli.Command{
+		Name:  "check",
+		Usage: "Check the template and preview it, without contacting Jira",
+		Description: "Parses the template given by --template, " +
+			"--template-file and --template-dir, and renders it for a " +
+			"sample issue, then again with some awkward summaries. " +
+			"An issue can be given to use the template configured for " +
+			"its instance",
+		ArgsUsage: "[ISSUE-NUMBER OR ISSUE-URL]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: argumentFixture,
+				Usage: "A Jira issue as JSON (as returned by the REST " +
+					"API) to use instead of the sample issue",
+			},
+		},
+		Action: templateCheckAction,
+	}
+}
+
+func templateCheckAction(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cli.NewExitError(
+			"incorrect number of arguments, see "+
+				"`jira-branch-helper template check --help` for full "+
+				"usage information",
+			errorExitCodeIncorrectNumberOfArguments,
+		)
+	}
+
+	conf, err := loadConfig(c.GlobalString(argumentConfig))
+	if err != nil {
+		return cli.NewExitError(
+			err.Error(),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	settings := resolveSettings(c, conf, c.Args().Get(0))
+	issueFormatter := &branchhelper.Jira{Config: conf.TemplateConfig}
+	templ, err := parseTemplate(issueFormatter, settings)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "template is invalid").Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	templ.Option("missingkey=error")
+
+	fixture, err := loadFixture(c.String(argumentFixture))
+	if err != nil {
+		return cli.NewExitError(
+			err.Error(),
+			errorExitCodeCouldNotParseIssue,
+		)
+	}
+
+	previews := issueFormatter.PreviewTemplate(
+		templ,
+		fixture,
+		branchhelper.SampleSummaries,
+	)
+	failed := false
+
+	for _, preview := range previews {
+		fmt.Printf("%s: %q\n", preview.Name, preview.Summary)
+
+		if preview.Err != nil {
+			failed = true
+			fmt.Printf("  error: %s\n", preview.Err)
+			continue
+		}
+
+		fmt.Printf("  %s\n", preview.Branch)
+	}
+
+	if failed {
+		return cli.NewExitError(
+			"template failed to render",
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	return nil
+}
+
+func loadFixture(path string) (*jira.Issue, error) {
+	if path == "" {
+		return branchhelper.SampleIssue(), nil
+	}
+
+	rawFixture, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read fixture")
+	}
+
+	fixture := &jira.Issue{}
+	if err := json.Unmarshal(rawFixture, fixture); err != nil {
+		return nil, errors.Wrap(err, "failed to parse fixture")
+	}
+
+	return fixture, nil
+}
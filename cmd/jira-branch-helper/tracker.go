@@ -0,0 +1,158 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"net/url"
+	"os"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// defaultTrackerTemplate is the template for issues that aren't from Jira,
+// which only have the fields every tracker has
+const defaultTrackerTemplate = "{{.Key | ToLower }}-" +
+	"{{.Title | Trim | KebabCase }}"
+
+// jiraOnlyArguments are the options that change the issue, which only work
+// with Jira
+var jiraOnlyArguments = []string{
+	argumentTransition,
+	argumentAssignSelf,
+	argumentLinkBranch,
+	argumentCommentBranch,
+}
+
+// trackerKind which tracker an issue is from, the one given as a flag, or
+// worked out from the URL of the issue
+func trackerKind(c *cli.Context, issueURL *url.URL) string {
+	if kind := c.GlobalString(argumentTracker); kind != "" {
+		return kind
+	}
+
+	if issueURL == nil || issueURL.Host == "" {
+		return branchhelper.TrackerJira
+	}
+
+	return branchhelper.DetectTracker(issueURL)
+}
+
+// trackerAction build the branch name for an issue in a tracker that isn't
+// Jira
+func trackerAction(
+	c *cli.Context,
+	conf config,
+	settings jiraSettings,
+	kind string,
+	rawIssueID string,
+) error {
+	for _, argument := range jiraOnlyArguments {
+		if c.GlobalIsSet(argument) {
+			return cli.NewExitError(
+				"--"+argument+" only works with jira issues",
+				errorExitCodeConfigFailure,
+			)
+		}
+	}
+
+	httpClient, err := newHTTPClient(c, nil)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "initialising http client failed").Error(),
+			errorExitCodeJiraInitFailure,
+		)
+	}
+
+	tracker, err := branchhelper.NewTracker(
+		kind,
+		httpClient,
+		c.GlobalString(argumentTrackerEndpoint),
+		c.GlobalString(argumentTrackerToken),
+	)
+
+	if err != nil {
+		return cli.NewExitError(err.Error(), errorExitCodeConfigFailure)
+	}
+
+	issueID, err := tracker.IssueStrategy().GetIssue(rawIssueID)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to parse the issue id").Error(),
+			errorExitCodeCouldNotParseIssue,
+		)
+	}
+
+	if settings.template == defaultTemplate || settings.template == "" {
+		settings.template = defaultTrackerTemplate
+	}
+
+	templ, err := parseTemplate(
+		&branchhelper.Jira{Config: conf.TemplateConfig},
+		settings,
+	)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to build branch name").Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	issue, err := tracker.GetIssue(ctx, issueID)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(
+				interrupted(ctx, err),
+				"failed to build branch name",
+			).Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	branchName, err := branchhelper.NewRenderer(templ).RenderTrackerIssue(issue)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to build branch name").Error(),
+			errorExitCodeBranchNameBuildFailure,
+		)
+	}
+
+	branchName, exitErr := avoidCollision(c, conf, branchName)
+	if exitErr != nil {
+		return exitErr
+	}
+
+	if _, err := os.Stdout.WriteString(branchName + "\n"); err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to flush branch name to buffer").Error(),
+			errorExitCodeBranchNameWriteError,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+// verifyExitCodePolicy is set in the exit code when the branch breaks the
+// policy. Exit statuses only have eight bits, and the errors use them all, so
+// this is the flag for issues that can't be used, which verify never exits
+// with otherwise
+const verifyExitCodePolicy = errorExitCodeCouldNotParseIssue
+
+// Exit codes for branches that break the policy. Along with the policy flag,
+// a flag says which rule is broken. Errors never set the policy flag, so the
+// rule flags can't be mistaken for them
+const (
+	verifyExitCodeNoIssueKey    = verifyExitCodePolicy
+	verifyExitCodeIssueNotFound = verifyExitCodePolicy | 1<<(iota-1)
+	verifyExitCodeProjectNotAllowed
+	verifyExitCodeStatusNotAllowed
+	verifyExitCodeNameMismatch
+)
+
+// verifyExitCodes the exit code for each result of verifying a branch
+var verifyExitCodes = map[string]int{
+	branchhelper.VerifyOK:                0,
+	branchhelper.VerifyNoIssueKey:        verifyExitCodeNoIssueKey,
+	branchhelper.VerifyIssueNotFound:     verifyExitCodeIssueNotFound,
+	branchhelper.VerifyProjectNotAllowed: verifyExitCodeProjectNotAllowed,
+	branchhelper.VerifyStatusNotAllowed:  verifyExitCodeStatusNotAllowed,
+	branchhelper.VerifyNameMismatch:      verifyExitCodeNameMismatch,
+}
+
+const (
+	// argumentStatus is the option to set the statuses issues can be in
+	argumentStatus = "status"
+	// argumentProject is the option to set the projects issues can be from
+	argumentProject = "project"
+	// argumentCheckName is the option to check the branch is named as the
+	// template would name it
+	argumentCheckName = "check-name"
+	// argumentFormat is the option to set how the result is shown
+	argumentFormat = "format"
+)
+
+// Formats the result of verifying a branch can be shown in
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+func verifyCommand() cli.Command {
+	return cli.Command{
+		Name:      "verify",
+		Usage:     "Check a branch references an open Jira issue",
+		ArgsUsage: "[BRANCH]",
+		Description: "Checks the branch given, or the one checked out, has " +
+			"the key of an issue in its name, and that the issue exists " +
+			"and isn't done. With --status the issue must be in one of " +
+			"the statuses instead, and with --project in one of the " +
+			"projects. With --check-name the branch must be the name the " +
+			"template gives the issue. When the branch breaks the policy " +
+			"the exit code has the 16 flag set: 16 alone if there is no " +
+			"issue key, with 1 if the issue doesn't exist, 2 if it is in " +
+			"another project, 4 if it is in another status, and 8 if the " +
+			"name doesn't match",
+		Flags: []cli.Flag{
+			cli.StringSliceFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_STATUS",
+				Name:   argumentStatus,
+				Usage:  "A status the issue can be in, can be given more than once",
+			},
+			cli.StringSliceFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_PROJECT",
+				Name:   argumentProject,
+				Usage:  "A project the issue can be from, can be given more than once",
+			},
+			cli.BoolFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_CHECK_NAME",
+				Name:   argumentCheckName,
+				Usage:  "Check the branch is named as the template would name it",
+			},
+			cli.StringFlag{
+				EnvVar: "JIRA_BRANCH_HELPER_FORMAT",
+				Name:   argumentFormat,
+				Usage:  "How to show the result, \"text\" or \"json\"",
+				Value:  formatText,
+			},
+		},
+		Action: verifyAction,
+	}
+}
+
+func verifyAction(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return cli.NewExitError(
+			"incorrect number of arguments, see "+
+				"`jira-branch-helper verify --help` for full usage information",
+			errorExitCodeIncorrectNumberOfArguments,
+		)
+	}
+
+	format := c.String(argumentFormat)
+	if format != formatText && format != formatJSON {
+		return cli.NewExitError(
+			fmt.Sprintf(
+				"unknown format %q, use %q or %q",
+				format,
+				formatText,
+				formatJSON,
+			),
+			errorExitCodeConfigFailure,
+		)
+	}
+
+	branch := c.Args().Get(0)
+	if branch == "" {
+		var err error
+
+		if branch, err = currentBranch(); err != nil {
+			return cli.NewExitError(
+				errors.Wrap(err, "failed to find the branch checked out").Error(),
+				errorExitCodeIncorrectNumberOfArguments,
+			)
+		}
+	}
+
+	policy := branchhelper.BranchPolicy{
+		Projects: c.StringSlice(argumentProject),
+		Statuses: c.StringSlice(argumentStatus),
+	}
+
+	// Branches without a key are reported without asking Jira
+	helper := &branchhelper.Jira{}
+
+	if key, found := branchhelper.IssueKeyFromBranch(branch); found {
+		conf, err := loadConfig(c.GlobalString(argumentConfig))
+		if err != nil {
+			return cli.NewExitError(
+				err.Error(),
+				errorExitCodeConfigFailure,
+			)
+		}
+
+		settings, exitErr := issueSettings(c, conf, key)
+		if exitErr != nil {
+			return exitErr
+		}
+
+		jiraClient, exitErr := newJiraClient(c, settings)
+		if exitErr != nil {
+			return exitErr
+		}
+
+		helper = branchhelper.NewJiraWithAPIVersion(
+			jiraClient,
+			settings.apiVersion,
+		)
+		helper.Config = conf.TemplateConfig
+
+		if c.Bool(argumentCheckName) {
+			policy.Template, err = parseTemplate(helper, settings)
+
+			if err != nil {
+				return cli.NewExitError(
+					errors.Wrap(err, "failed to parse branch template").Error(),
+					errorExitCodeBranchNameBuildFailure,
+				)
+			}
+		}
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	result, err := helper.VerifyBranch(ctx, branch, policy)
+
+	if err != nil {
+		return cli.NewExitError(
+			errors.Wrap(interrupted(ctx, err), "failed to verify branch").Error(),
+			errorExitCodeJiraInitFailure,
+		)
+	}
+
+	return reportVerification(format, result)
+}
+
+// issueSettings how to reach the Jira an issue is in, the one given by
+// --jira-endpoint, the instance that owns the issue, or the only instance
+// configured
+func issueSettings(
+	c *cli.Context,
+	conf config,
+	key string,
+) (jiraSettings, *cli.ExitError) {
+	settings := resolveSettings(c, conf, key)
+
+	if settings.endpoint == "" && len(conf.Instances) == 1 {
+		settings = resolveSettings(c, conf, conf.Instances[0].Endpoint)
+	}
+
+	if settings.endpoint == "" {
+		return settings, cli.NewExitError(
+			"you must provide a Jira URL via Flag or environment variable, "+
+				"or configure an instance for the project",
+			errorExitCodeNoEndpointURL,
+		)
+	}
+
+	settings.endpoint = normaliseEndpointURL(settings.endpoint)
+
+	return settings, checkAPIVersion(settings.apiVersion)
+}
+
+// reportVerification show the result, and exit with the code for it if the
+// branch doesn't follow the policy
+func reportVerification(
+	format string,
+	result branchhelper.BranchVerification,
+) error {
+	exitCode := verifyExitCodes[result.Result]
+
+	if format == formatJSON {
+		if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+			return cli.NewExitError(
+				errors.Wrap(err, "failed to write result").Error(),
+				errorExitCodeBranchNameWriteError,
+			)
+		}
+
+		if exitCode != 0 {
+			return cli.NewExitError("", exitCode)
+		}
+
+		return nil
+	}
+
+	if exitCode != 0 {
+		return cli.NewExitError(result.Message, exitCode)
+	}
+
+	if _, err := fmt.Fprintln(os.Stdout, result.Message); err != nil {
+		return cli.NewExitError(
+			errors.Wrap(err, "failed to write result").Error(),
+			errorExitCodeBranchNameWriteError,
+		)
+	}
+
+	return nil
+}
+
+// currentBranch the name of the branch checked out in the repository we are
+// in
+func currentBranch() (string, error) {
+	out, err := exec.Command("git", "symbolic-ref", "--short", "HEAD").Output()
+	if err != nil {
+		return "", errors.New(
+			"not on a branch, give the name of the branch to check",
+		)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,517 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelpertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/pkg/errors"
+)
+
+// issuePathReg splits the path of an issue into the issue and what of it is
+// asked for, e.g. "issue/TST-123/comment" into "TST-123" and "comment"
+var issuePathReg = regexp.MustCompile(`^issue/([^/]+)(?:/([a-z]+))?$`)
+
+var keyInReg = regexp.MustCompile(`(?i)^\s*key\s+in\s*\(([^)]*)\)\s*$`)
+
+// AddIssue add an issue, or replace one with the same key. Fields are as the
+// API has them, e.g. {"summary": "Login page", "labels": ["web"]}. Issues
+// with nil fields are answered without any, as Jira sometimes does for
+// related issues
+func (s *Server) AddIssue(key string, fields map[string]interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var copied map[string]interface{}
+	if fields != nil {
+		copied = map[string]interface{}{}
+	}
+
+	for name, value := range fields {
+		copied[name] = value
+	}
+
+	issue, ok := s.issues[key]
+	if !ok {
+		issue = &fakeIssue{id: strconv.Itoa(s.nextIssueID), key: key}
+		s.nextIssueID++
+		s.issues[key] = issue
+	}
+
+	issue.fields = copied
+}
+
+// AddIssueJSON add an issue as the API returns it, e.g.
+// {"key": "TST-123", "fields": {"summary": "Login page"}}
+func (s *Server) AddIssueJSON(raw string) error {
+	issue := struct {
+		Key    string                 `json:"key"`
+		Fields map[string]interface{} `json:"fields"`
+	}{}
+
+	if err := json.Unmarshal([]byte(raw), &issue); err != nil {
+		return errors.Wrap(err, "failed to read issue")
+	}
+
+	if issue.Key == "" {
+		return errors.New("issue has no key")
+	}
+
+	s.AddIssue(issue.Key, issue.Fields)
+
+	return nil
+}
+
+// AddField add a field, for the Field template function to find
+func (s *Server) AddField(field branchhelper.FieldMetadata) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.fields = append(s.fields, field)
+}
+
+// SetUser change who the fake Jira thinks is logged in
+func (s *Server) SetUser(user branchhelper.User) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.user = user
+}
+
+// SetTransitions set the transitions an issue that has been added can go
+// through. Going through one changes the status of the issue to the one it
+// goes to
+func (s *Server) SetTransitions(
+	key string,
+	transitions ...branchhelper.Transition,
+) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if issue, ok := s.issues[key]; ok {
+		issue.transitions = transitions
+	}
+}
+
+// SetSearchResults set the issues a JQL query finds. Queries without results
+// set find the issues they list with "key in (...)", and are refused otherwise
+func (s *Server) SetSearchResults(jql string, keys ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.searches[jql] = keys
+}
+
+// Status the name of the status of an issue
+func (s *Server) Status(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if issue, ok := s.issues[key]; ok {
+		if status, ok := issue.fields["status"].(map[string]interface{}); ok {
+			name, _ := status["name"].(string)
+
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Assignee who an issue is assigned to, nil if it isn't
+func (s *Server) Assignee(key string) *branchhelper.User {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	issue, ok := s.issues[key]
+	if !ok || issue.fields["assignee"] == nil {
+		return nil
+	}
+
+	user := &branchhelper.User{}
+	if raw, err := json.Marshal(issue.fields["assignee"]); err == nil {
+		_ = json.Unmarshal(raw, user)
+	}
+
+	return user
+}
+
+// Comments the comments on an issue, with the text of any ADF documents
+func (s *Server) Comments(key string) []branchhelper.Comment {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	comments := []branchhelper.Comment{}
+	issue, ok := s.issues[key]
+	if !ok {
+		return comments
+	}
+
+	for _, stored := range issue.comments {
+		comment := branchhelper.Comment{}
+		comment.ID, _ = stored["id"].(string)
+
+		switch body := stored["body"].(type) {
+		case string:
+			comment.Body = body
+		default:
+			raw, _ := json.Marshal(body)
+			if document, ok := branchhelper.ParseADF(string(raw)); ok {
+				comment.Body = document.PlainText()
+				comment.Document = &document
+			}
+		}
+
+		comments = append(comments, comment)
+	}
+
+	return comments
+}
+
+// RemoteLinks the remote links on an issue
+func (s *Server) RemoteLinks(key string) []branchhelper.RemoteLink {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if issue, ok := s.issues[key]; ok {
+		return append([]branchhelper.RemoteLink{}, issue.links...)
+	}
+
+	return []branchhelper.RemoteLink{}
+}
+
+func (s *Server) serveAPI(
+	w http.ResponseWriter,
+	r *http.Request,
+	version string,
+	path string,
+	body []byte,
+) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case path == "field" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.fields)
+	case path == "myself" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.user)
+	case path == "search":
+		s.serveSearch(w, r, version, body)
+	case issuePathReg.MatchString(path):
+		match := issuePathReg.FindStringSubmatch(path)
+		issue := s.findIssue(match[1])
+
+		if issue == nil {
+			writeError(
+				w,
+				http.StatusNotFound,
+				"Issue does not exist or you do not have permission to see it.",
+			)
+
+			return
+		}
+
+		s.serveIssue(w, r, version, issue, match[2], body)
+	default:
+		writeError(w, http.StatusNotFound, "No such endpoint")
+	}
+}
+
+func (s *Server) findIssue(keyOrID string) *fakeIssue {
+	if issue, ok := s.issues[keyOrID]; ok {
+		return issue
+	}
+
+	for _, issue := range s.issues {
+		if issue.id == keyOrID {
+			return issue
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) serveIssue(
+	w http.ResponseWriter,
+	r *http.Request,
+	version string,
+	issue *fakeIssue,
+	part string,
+	body []byte,
+) {
+	switch {
+	case part == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.issueJSON(
+			version,
+			issue,
+			r.URL.Query().Get("fields"),
+			r.URL.Query().Get("expand"),
+		))
+	case part == "transitions" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"transitions": append([]branchhelper.Transition{}, issue.transitions...),
+		})
+	case part == "transitions" && r.Method == http.MethodPost:
+		s.transition(w, issue, body)
+	case part == "assignee" && r.Method == http.MethodPut:
+		s.assign(w, issue, body)
+	case part == "comment" && r.Method == http.MethodGet:
+		startAt, _ := strconv.Atoi(r.URL.Query().Get("startAt"))
+		maxResults, err := strconv.Atoi(r.URL.Query().Get("maxResults"))
+		if err != nil {
+			maxResults = len(issue.comments)
+		}
+
+		found := []map[string]interface{}{}
+		for i, comment := range issue.comments {
+			if i >= startAt && len(found) < maxResults {
+				found = append(found, comment)
+			}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"startAt":    startAt,
+			"maxResults": maxResults,
+			"comments":   found,
+			"total":      len(issue.comments),
+		})
+	case part == "comment" && r.Method == http.MethodPost:
+		s.comment(w, issue, body)
+	case part == "remotelink" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, append([]branchhelper.RemoteLink{}, issue.links...))
+	case part == "remotelink" && r.Method == http.MethodPost:
+		s.link(w, issue, body)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// issueJSON an issue as the API returns it, with only the fields asked for
+func (s *Server) issueJSON(
+	version string,
+	issue *fakeIssue,
+	fields string,
+	expand string,
+) map[string]interface{} {
+	wanted := map[string]bool{}
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			wanted[field] = true
+		}
+	}
+
+	all := len(wanted) == 0 || wanted["*all"] || wanted["*navigable"]
+	filtered := map[string]interface{}{}
+
+	for name, value := range issue.fields {
+		if all || wanted[name] {
+			filtered[name] = value
+		}
+	}
+
+	result := map[string]interface{}{
+		"id":   issue.id,
+		"key":  issue.key,
+		"self": s.URL + "rest/api/" + version + "/issue/" + issue.id,
+	}
+
+	if issue.fields != nil {
+		result["fields"] = filtered
+	}
+
+	if strings.Contains(expand, "names") {
+		names := map[string]string{}
+		for _, field := range s.fields {
+			if _, ok := filtered[field.ID]; ok {
+				names[field.ID] = field.Name
+			}
+		}
+
+		result["names"] = names
+	}
+
+	return result
+}
+
+func (s *Server) serveSearch(
+	w http.ResponseWriter,
+	r *http.Request,
+	version string,
+	body []byte,
+) {
+	query := struct {
+		JQL        string   `json:"jql"`
+		StartAt    int      `json:"startAt"`
+		MaxResults int      `json:"maxResults"`
+		Fields     []string `json:"fields"`
+	}{MaxResults: 50}
+
+	switch r.Method {
+	case http.MethodGet:
+		query.JQL = r.URL.Query().Get("jql")
+		query.StartAt, _ = strconv.Atoi(r.URL.Query().Get("startAt"))
+		if maxResults, err := strconv.Atoi(r.URL.Query().Get("maxResults")); err == nil {
+			query.MaxResults = maxResults
+		}
+		if fields := r.URL.Query().Get("fields"); fields != "" {
+			query.Fields = strings.Split(fields, ",")
+		}
+	case http.MethodPost:
+		if err := json.Unmarshal(body, &query); err != nil {
+			writeError(w, http.StatusBadRequest, "Search has no JQL")
+
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+
+		return
+	}
+
+	keys, ok := s.searches[query.JQL]
+	if !ok {
+		match := keyInReg.FindStringSubmatch(query.JQL)
+		if match == nil {
+			writeError(w, http.StatusBadRequest, "Error in the JQL Query")
+
+			return
+		}
+
+		keys = []string{}
+		for _, key := range strings.Split(match[1], ",") {
+			if key = strings.ToUpper(strings.TrimSpace(key)); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	issues := []*fakeIssue{}
+	for _, key := range keys {
+		if issue, ok := s.issues[key]; ok {
+			issues = append(issues, issue)
+		}
+	}
+
+	found := []map[string]interface{}{}
+	for i, issue := range issues {
+		if i < query.StartAt || len(found) >= query.MaxResults {
+			continue
+		}
+
+		found = append(found, s.issueJSON(
+			version,
+			issue,
+			strings.Join(query.Fields, ","),
+			"",
+		))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"startAt":    query.StartAt,
+		"maxResults": query.MaxResults,
+		"total":      len(issues),
+		"issues":     found,
+	})
+}
+
+func (s *Server) transition(w http.ResponseWriter, issue *fakeIssue, body []byte) {
+	request := struct {
+		Transition struct {
+			ID string `json:"id"`
+		} `json:"transition"`
+	}{}
+	_ = json.Unmarshal(body, &request)
+
+	for _, transition := range issue.transitions {
+		if transition.ID == request.Transition.ID {
+			issue.setField("status", map[string]interface{}{
+				"name": transition.To.Name,
+			})
+			writeJSON(w, http.StatusNoContent, nil)
+
+			return
+		}
+	}
+
+	writeError(
+		w,
+		http.StatusBadRequest,
+		"It seems that you have tried to perform a workflow operation "+
+			"that is not valid for the current state of this issue.",
+	)
+}
+
+func (s *Server) assign(w http.ResponseWriter, issue *fakeIssue, body []byte) {
+	user := branchhelper.User{}
+	if err := json.Unmarshal(body, &user); err != nil {
+		writeError(w, http.StatusBadRequest, "Assign to a user")
+
+		return
+	}
+
+	if user.Same(s.user) {
+		user = s.user
+	}
+
+	if user.Name == "" && user.AccountID == "" && user.Key == "" {
+		issue.setField("assignee", nil)
+	} else {
+		issue.setField("assignee", user)
+	}
+
+	writeJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) comment(w http.ResponseWriter, issue *fakeIssue, body []byte) {
+	comment := map[string]interface{}{}
+	if err := json.Unmarshal(body, &comment); err != nil || comment["body"] == nil {
+		writeError(w, http.StatusBadRequest, "Comment body can not be empty!")
+
+		return
+	}
+
+	comment["id"] = strconv.Itoa(s.nextCommentID)
+	s.nextCommentID++
+	issue.comments = append(issue.comments, comment)
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+func (s *Server) link(w http.ResponseWriter, issue *fakeIssue, body []byte) {
+	link := branchhelper.RemoteLink{}
+	if err := json.Unmarshal(body, &link); err != nil || link.Object.URL == "" {
+		writeError(w, http.StatusBadRequest, "The link needs a URL")
+
+		return
+	}
+
+	link.ID = s.nextLinkID
+	s.nextLinkID++
+	issue.links = append(issue.links, link)
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"id":   link.ID,
+		"self": s.URL + "rest/api/2/issue/" + issue.id + "/remotelink/" + strconv.Itoa(link.ID),
+	})
+}
@@ -0,0 +1,353 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package branchhelpertest is a fake Jira for testing code that uses
+// jira-branch-helper, or the jira-branch-helper command, without a real Jira.
+// It serves issues, fields, search, transitions, assignees, comments and
+// remote links from memory, in versions 2 and 3 of the REST API
+package branchhelpertest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+)
+
+// SessionCookie is the cookie the fake Jira gives out for session logins
+const SessionCookie = "JSESSIONID"
+
+// sessionValue is the value of the session cookie
+const sessionValue = "branchhelpertest-session"
+
+// apiPathReg splits a path into the version of the API and the rest, e.g.
+// "/rest/api/3/issue/TST-123" into "3" and "issue/TST-123"
+var apiPathReg = regexp.MustCompile(`^/rest/api/(2|3)/(.*)$`)
+
+// Request is a request the fake Jira received
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+}
+
+// Server is a fake Jira, served on a local port until it is closed. It is
+// safe for concurrent use, so can be changed while it is being used
+type Server struct {
+	// URL is the base URL of the fake Jira, with a trailing slash, to use
+	// as the endpoint
+	URL string
+
+	server *httptest.Server
+
+	mutex         sync.Mutex
+	issues        map[string]*fakeIssue
+	fields        []branchhelper.FieldMetadata
+	user          branchhelper.User
+	searches      map[string][]string
+	username      string
+	password      string
+	basicAuth     bool
+	sessionAuth   bool
+	failures      []*failure
+	latency       time.Duration
+	requests      []Request
+	nextIssueID   int
+	nextCommentID int
+	nextLinkID    int
+}
+
+// fakeIssue is an issue and everything attached to it
+type fakeIssue struct {
+	id          string
+	key         string
+	fields      map[string]interface{}
+	transitions []branchhelper.Transition
+	comments    []map[string]interface{}
+	links       []branchhelper.RemoteLink
+}
+
+// setField change a field of the issue, giving it fields if it had none
+func (i *fakeIssue) setField(name string, value interface{}) {
+	if i.fields == nil {
+		i.fields = map[string]interface{}{}
+	}
+
+	i.fields[name] = value
+}
+
+// failure is a way requests will fail
+type failure struct {
+	path      string
+	status    int
+	remaining int
+}
+
+// DefaultFields are the fields the fake Jira has, until more are added
+func DefaultFields() []branchhelper.FieldMetadata {
+	return []branchhelper.FieldMetadata{
+		{ID: "summary", Name: "Summary"},
+		{ID: "issuetype", Name: "Issue Type"},
+		{ID: "status", Name: "Status"},
+		{ID: "labels", Name: "Labels"},
+		{ID: "assignee", Name: "Assignee"},
+		{ID: "description", Name: "Description"},
+		{ID: "parent", Name: "Parent"},
+	}
+}
+
+// DefaultUser is who the fake Jira thinks is logged in, until it is changed
+func DefaultUser() branchhelper.User {
+	return branchhelper.User{
+		Name:        "test",
+		Key:         "test",
+		AccountID:   "5b10a2844c20165700ede21g",
+		DisplayName: "Test User",
+	}
+}
+
+// NewServer start a fake Jira with no issues, that anyone can use
+func NewServer() *Server {
+	s := &Server{
+		issues:        map[string]*fakeIssue{},
+		fields:        DefaultFields(),
+		user:          DefaultUser(),
+		searches:      map[string][]string{},
+		nextIssueID:   10000,
+		nextCommentID: 20000,
+		nextLinkID:    30000,
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL + "/"
+
+	return s
+}
+
+// Close stop the fake Jira
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// RequireBasicAuth only answer requests with these basic auth credentials
+func (s *Server) RequireBasicAuth(username string, password string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.basicAuth = true
+	s.username = username
+	s.password = password
+}
+
+// RequireSession only answer requests with a session cookie, which is given
+// out for logging in to /rest/auth/1/session with these credentials. Basic
+// auth is accepted too if it is required
+func (s *Server) RequireSession(username string, password string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sessionAuth = true
+	s.username = username
+	s.password = password
+}
+
+// Fail answer requests whose path in the API starts with path, e.g.
+// "issue/TST-123" or "field", with a status. The next times requests fail,
+// or every request if times is zero
+func (s *Server) Fail(path string, status int, times int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures = append(
+		s.failures,
+		&failure{path: path, status: status, remaining: times},
+	)
+}
+
+// ClearFailures answer requests normally again
+func (s *Server) ClearFailures() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.failures = nil
+}
+
+// SetLatency wait before answering each request, or until the request is
+// cancelled
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.latency = latency
+}
+
+// Requests the requests the fake Jira has received, oldest first
+func (s *Server) Requests() []Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Request{}, s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	latency, status := s.receive(r, body)
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if status != 0 {
+		writeError(w, status, http.StatusText(status))
+
+		return
+	}
+
+	if r.URL.Path == "/rest/auth/1/session" {
+		s.serveSession(w, r, body)
+
+		return
+	}
+
+	if !s.authorised(r) {
+		writeError(w, http.StatusUnauthorized, "You are not authenticated")
+
+		return
+	}
+
+	match := apiPathReg.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		writeError(w, http.StatusNotFound, "No such endpoint")
+
+		return
+	}
+
+	s.serveAPI(w, r, match[1], match[2], body)
+}
+
+// receive record a request, returning how long to wait before answering it,
+// and the status to fail it with, if it should fail
+func (s *Server) receive(r *http.Request, body []byte) (time.Duration, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Body:   body,
+	})
+
+	apiPath := r.URL.Path
+	if match := apiPathReg.FindStringSubmatch(r.URL.Path); match != nil {
+		apiPath = match[2]
+	}
+
+	for i, f := range s.failures {
+		if !strings.HasPrefix(apiPath, f.path) {
+			continue
+		}
+
+		if f.remaining > 0 {
+			f.remaining--
+
+			if f.remaining == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+
+		return s.latency, f.status
+	}
+
+	return s.latency, 0
+}
+
+func (s *Server) authorised(r *http.Request) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.basicAuth && !s.sessionAuth {
+		return true
+	}
+
+	if username, password, ok := r.BasicAuth(); ok && s.basicAuth {
+		return username == s.username && password == s.password
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil && s.sessionAuth {
+		return cookie.Value == sessionValue
+	}
+
+	return false
+}
+
+func (s *Server) serveSession(w http.ResponseWriter, r *http.Request, body []byte) {
+	credentials := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{}
+
+	if r.Method != http.MethodPost || json.Unmarshal(body, &credentials) != nil {
+		writeError(w, http.StatusBadRequest, "Log in with a username and password")
+
+		return
+	}
+
+	s.mutex.Lock()
+	valid := credentials.Username == s.username && credentials.Password == s.password
+	s.mutex.Unlock()
+
+	if !valid {
+		writeError(w, http.StatusUnauthorized, "Login failed")
+
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Value: sessionValue, Path: "/"})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"session": map[string]string{"name": SessionCookie, "value": sessionValue},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+// writeError answer with an error, in the way Jira does
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"errorMessages": []string{message},
+		"errors":        map[string]string{},
+	})
+}
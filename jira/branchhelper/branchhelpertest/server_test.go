@@ -0,0 +1,343 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelpertest_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper/branchhelpertest"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var server *Server
+	var client *jira.Client
+
+	BeforeEach(func() {
+		server = NewServer()
+		server.AddIssue("TST-123", map[string]interface{}{
+			"summary":   "Implement the login page",
+			"labels":    []string{"frontend"},
+			"issuetype": map[string]interface{}{"name": "Story"},
+			"status":    map[string]interface{}{"name": "To Do"},
+		})
+
+		var err error
+		client, err = jira.NewClient(nil, server.URL)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Serves branch names", func() {
+		actual, err := branchhelper.NewJira(client).FormatIssue(
+			"TST-123",
+			"{{.Key | ToLower}}-{{.Fields.Summary | KebabCase}}",
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("tst-123-implement-the-login-page"))
+	})
+	It("Serves version 3 of the API", func() {
+		helper := branchhelper.NewJiraWithAPIVersion(client, branchhelper.APIVersion3)
+
+		actual, err := helper.FormatIssue("TST-123", "{{.Type}}/{{.Key}}")
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("Story/TST-123"))
+		Expect(server.Requests()[0].Path).To(Equal("/rest/api/3/issue/TST-123"))
+	})
+	It("Only serves the fields asked for", func() {
+		issue, _, err := branchhelper.IssueClient{Client: client}.Get(
+			"TST-123",
+			&jira.GetQueryOptions{Fields: "summary", Expand: "names"},
+		)
+
+		Expect(err).To(BeNil())
+		Expect(issue.Fields.Summary).To(Equal("Implement the login page"))
+		Expect(issue.Fields.Labels).To(BeEmpty())
+		Expect(issue.Names).To(Equal(map[string]string{"summary": "Summary"}))
+	})
+	It("Serves issues added as JSON", func() {
+		Expect(server.AddIssueJSON(`{
+			"key": "TST-124",
+			"fields": {"summary": "Log out", "customfield_10020": {"value": "Web"}}
+		}`)).To(Succeed())
+		server.AddField(branchhelper.FieldMetadata{
+			ID:     "customfield_10020",
+			Name:   "Team",
+			Custom: true,
+		})
+
+		actual, err := branchhelper.NewJira(client).FormatIssue(
+			"TST-124",
+			`{{Field "Team" | ToLower}}/{{.Fields.Summary | KebabCase}}`,
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("web/log-out"))
+	})
+	It("Serves issues without fields", func() {
+		server.AddIssue("TST-2", nil)
+
+		issue, _, err := branchhelper.IssueClient{Client: client}.Get("TST-2", nil)
+
+		Expect(err).To(BeNil())
+		Expect(issue.Key).To(Equal("TST-2"))
+		Expect(issue.Fields).To(BeNil())
+	})
+	It("Doesn't serve issues it doesn't have", func() {
+		_, _, err := branchhelper.IssueClient{Client: client}.Get("TST-999", nil)
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Searches", func() {
+		server.AddIssue("TST-124", map[string]interface{}{"summary": "Log out"})
+		server.SetSearchResults("status = Done", "TST-124")
+
+		found := func(jql string) []string {
+			req, err := client.NewRequest(
+				"POST",
+				"rest/api/2/search",
+				map[string]interface{}{"jql": jql},
+			)
+			Expect(err).To(BeNil())
+
+			result := struct {
+				Issues []jira.Issue `json:"issues"`
+			}{}
+			_, err = client.Do(req, &result)
+			Expect(err).To(BeNil())
+
+			keys := []string{}
+			for _, issue := range result.Issues {
+				keys = append(keys, issue.Key)
+			}
+
+			return keys
+		}
+
+		Expect(found("status = Done")).To(Equal([]string{"TST-124"}))
+		Expect(found("key in (TST-124, tst-123, TST-999)")).To(Equal(
+			[]string{"TST-124", "TST-123"},
+		))
+	})
+	It("Refuses searches it doesn't understand", func() {
+		req, err := client.NewRequest(
+			"POST",
+			"rest/api/2/search",
+			map[string]interface{}{"jql": "project = TST"},
+		)
+		Expect(err).To(BeNil())
+
+		resp, err := client.Do(req, nil)
+
+		Expect(err).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+	It("Transitions issues", func() {
+		transition := branchhelper.Transition{ID: "21", Name: "Start Progress"}
+		transition.To.Name = "In Progress"
+		server.SetTransitions("TST-123", transition)
+
+		actual, err := branchhelper.TransitionsClient{Client: client}.TransitionIssue(
+			"TST-123",
+			"In Progress",
+			false,
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual.ID).To(Equal("21"))
+		Expect(server.Status("TST-123")).To(Equal("In Progress"))
+	})
+	It("Assigns issues", func() {
+		user, err := branchhelper.AssignClient{Client: client}.AssignSelf(
+			"TST-123",
+			false,
+			false,
+		)
+
+		Expect(err).To(BeNil())
+		Expect(user).To(Equal(DefaultUser()))
+		Expect(server.Assignee("TST-123")).To(Equal(&user))
+	})
+	It("Comments and links branches", func() {
+		links := branchhelper.LinksClient{Client: client}
+		branch := branchhelper.Branch{
+			Name:          "tst-123-login",
+			RepositoryURL: "https://github.com/org/repo",
+		}
+
+		linked, err := links.LinkBranch("TST-123", branch, false)
+		Expect(err).To(BeNil())
+		Expect(linked).To(BeTrue())
+
+		commented, err := links.CommentBranch("TST-123", branch, false)
+		Expect(err).To(BeNil())
+		Expect(commented).To(BeTrue())
+
+		commented, err = links.CommentBranch("TST-123", branch, false)
+		Expect(err).To(BeNil())
+		Expect(commented).To(BeFalse())
+
+		Expect(server.RemoteLinks("TST-123")).To(HaveLen(1))
+		Expect(server.RemoteLinks("TST-123")[0].Object.URL).To(Equal(branch.URL()))
+		Expect(server.Comments("TST-123")).To(HaveLen(1))
+		Expect(server.Comments("TST-123")[0].Body).To(Equal(branch.Comment()))
+	})
+	It("Pages comments", func() {
+		links := branchhelper.LinksClient{Client: client}
+		for _, body := range []string{"One", "Two", "Three"} {
+			Expect(links.AddComment(
+				"TST-123",
+				branchhelper.Comment{Body: body},
+			)).To(Succeed())
+		}
+
+		req, err := client.NewRequest(
+			"GET",
+			"rest/api/2/issue/TST-123/comment?startAt=1&maxResults=1",
+			nil,
+		)
+		Expect(err).To(BeNil())
+
+		page := struct {
+			Total    int `json:"total"`
+			Comments []struct {
+				Body string `json:"body"`
+			} `json:"comments"`
+		}{}
+		_, err = client.Do(req, &page)
+
+		Expect(err).To(BeNil())
+		Expect(page.Total).To(Equal(3))
+		Expect(page.Comments).To(HaveLen(1))
+		Expect(page.Comments[0].Body).To(Equal("Two"))
+	})
+	It("Keeps version 3 comments as documents", func() {
+		links := branchhelper.LinksClient{
+			Client:     client,
+			APIVersion: branchhelper.APIVersion3,
+		}
+
+		_, err := links.CommentBranch(
+			"TST-123",
+			branchhelper.Branch{Name: "tst-123-login"},
+			false,
+		)
+
+		Expect(err).To(BeNil())
+		Expect(server.Comments("TST-123")[0].Document).ToNot(BeNil())
+		Expect(server.Comments("TST-123")[0].Body).To(ContainSubstring("tst-123-login"))
+	})
+	Context("With basic auth", func() {
+		BeforeEach(func() {
+			server.RequireBasicAuth("user", "secret")
+		})
+
+		It("Refuses requests without it", func() {
+			_, _, err := branchhelper.IssueClient{Client: client}.Get("TST-123", nil)
+
+			Expect(err).ToNot(BeNil())
+		})
+		It("Answers requests with it", func() {
+			httpClient := &http.Client{Transport: &jira.BasicAuthTransport{
+				Username: "user",
+				Password: "secret",
+			}}
+			client, err := jira.NewClient(httpClient, server.URL)
+			Expect(err).To(BeNil())
+
+			_, _, err = branchhelper.IssueClient{Client: client}.Get("TST-123", nil)
+
+			Expect(err).To(BeNil())
+		})
+	})
+	Context("With sessions", func() {
+		BeforeEach(func() {
+			server.RequireSession("user", "secret")
+		})
+
+		It("Refuses the wrong password", func() {
+			_, err := client.Authentication.AcquireSessionCookie("user", "wrong")
+
+			Expect(err).ToNot(BeNil())
+		})
+		It("Answers requests once logged in", func() {
+			_, err := client.Authentication.AcquireSessionCookie("user", "secret")
+			Expect(err).To(BeNil())
+
+			_, _, err = branchhelper.IssueClient{Client: client}.Get("TST-123", nil)
+
+			Expect(err).To(BeNil())
+		})
+	})
+	It("Fails requests a number of times", func() {
+		server.Fail("issue/TST-123", http.StatusServiceUnavailable, 1)
+
+		_, resp, err := branchhelper.IssueClient{Client: client}.Get("TST-123", nil)
+		Expect(err).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+		_, _, err = branchhelper.IssueClient{Client: client}.Get("TST-123", nil)
+		Expect(err).To(BeNil())
+	})
+	It("Fails requests until cleared", func() {
+		server.Fail("field", http.StatusInternalServerError, 0)
+
+		_, err := branchhelper.FieldsClient{Client: client}.GetFields()
+		Expect(err).ToNot(BeNil())
+		_, err = branchhelper.FieldsClient{Client: client}.GetFields()
+		Expect(err).ToNot(BeNil())
+
+		server.ClearFailures()
+
+		_, err = branchhelper.FieldsClient{Client: client}.GetFields()
+		Expect(err).To(BeNil())
+	})
+	It("Answers slowly", func() {
+		server.SetLatency(time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, _, err := branchhelper.IssueClient{Client: client}.GetContext(
+			ctx,
+			"TST-123",
+			nil,
+		)
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Records requests", func() {
+		_, _ = branchhelper.NewJira(client).FormatIssue("TST-123", "{{.Key}}")
+
+		requests := server.Requests()
+
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Method).To(Equal("GET"))
+		Expect(requests[0].Query.Get("fields")).To(Equal("summary"))
+	})
+})
@@ -0,0 +1,160 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ADFNode is a node in an Atlassian Document Format document, the rich text
+// format version 3 of the Jira API uses for descriptions and comments
+type ADFNode struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Marks   []ADFMark              `json:"marks,omitempty"`
+	Content []ADFNode              `json:"content,omitempty"`
+}
+
+// ADFMark is formatting applied to a text node, like code or a link
+type ADFMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// adfInlineNodes are the nodes that sit within a line of text, every other
+// node starts a new line
+var adfInlineNodes = map[string]bool{
+	"text":       true,
+	"hardBreak":  true,
+	"mention":    true,
+	"emoji":      true,
+	"inlineCard": true,
+	"status":     true,
+	"date":       true,
+}
+
+// NewADFDocument wraps some nodes in an ADF document
+func NewADFDocument(content ...ADFNode) ADFNode {
+	return ADFNode{
+		Type:    "doc",
+		Version: 1,
+		Content: content,
+	}
+}
+
+// ParseADF reads an ADF document, returning false if the raw text isn't one
+func ParseADF(raw string) (ADFNode, bool) {
+	raw = strings.TrimSpace(raw)
+	doc := ADFNode{}
+
+	if !strings.HasPrefix(raw, "{") {
+		return doc, false
+	}
+
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return doc, false
+	}
+
+	return doc, doc.Type == "doc"
+}
+
+// PlainText the text of the document without any formatting, with blocks
+// like paragraphs and list items on their own lines
+func (n ADFNode) PlainText() string {
+	lines := []string{}
+	line := ""
+
+	for _, child := range n.Content {
+		if adfInlineNodes[child.Type] {
+			line += child.inlineText()
+
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+
+		if text := child.PlainText(); text != "" {
+			lines = append(lines, text)
+		}
+	}
+
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (n ADFNode) inlineText() string {
+	switch n.Type {
+	case "text":
+		return n.Text
+	case "hardBreak":
+		return "\n"
+	case "inlineCard":
+		return n.attr("url")
+	case "emoji":
+		if text := n.attr("text"); text != "" {
+			return text
+		}
+
+		return n.attr("shortName")
+	default:
+		return n.attr("text")
+	}
+}
+
+func (n ADFNode) attr(name string) string {
+	if value, ok := n.Attrs[name].(string); ok {
+		return value
+	}
+
+	return ""
+}
+
+// plainText is the PlainText template function. Strings that aren't ADF are
+// returned unchanged, so it is safe to use on version 2 of the API too
+func plainText(value interface{}) string {
+	if doc, ok := value.(ADFNode); ok {
+		return doc.PlainText()
+	}
+
+	return fieldValueString(value)
+}
+
+// adfFromMap reads an ADF document that has already been decoded as JSON,
+// as custom rich text fields are
+func adfFromMap(value map[string]interface{}) (ADFNode, bool) {
+	if value["type"] != "doc" {
+		return ADFNode{}, false
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ADFNode{}, false
+	}
+
+	return ParseADF(string(raw))
+}
@@ -0,0 +1,109 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testADFDescription = `{
+	"type": "doc",
+	"version": 1,
+	"content": [
+		{"type": "heading", "attrs": {"level": 1}, "content": [
+			{"type": "text", "text": "Login page"}
+		]},
+		{"type": "paragraph", "content": [
+			{"type": "text", "text": "Ask "},
+			{"type": "mention", "attrs": {"id": "1", "text": "@Billie"}},
+			{"type": "text", "text": " about the ", "marks": [{"type": "em"}]},
+			{"type": "emoji", "attrs": {"shortName": ":lock:", "text": "🔒"}},
+			{"type": "hardBreak"},
+			{"type": "text", "text": "See "},
+			{"type": "inlineCard", "attrs": {"url": "https://example.com/design"}}
+		]},
+		{"type": "bulletList", "content": [
+			{"type": "listItem", "content": [{"type": "paragraph", "content": [
+				{"type": "text", "text": "Username"}
+			]}]},
+			{"type": "listItem", "content": [{"type": "paragraph", "content": [
+				{"type": "status", "attrs": {"text": "DONE"}}
+			]}]}
+		]}
+	]
+}`
+
+var _ = Describe("ADF", func() {
+	It("Reads documents", func() {
+		doc, ok := ParseADF(testADFDescription)
+
+		Expect(ok).To(BeTrue())
+		Expect(doc.Type).To(Equal("doc"))
+	})
+	It("Does not read other text", func() {
+		for _, raw := range []string{"", "Login page", `{"type": "paragraph"}`, "{"} {
+			_, ok := ParseADF(raw)
+
+			Expect(ok).To(BeFalse())
+		}
+	})
+	It("Converts documents to plain text", func() {
+		doc, _ := ParseADF(testADFDescription)
+
+		Expect(doc.PlainText()).To(Equal(
+			"Login page\n" +
+				"Ask @Billie about the 🔒\n" +
+				"See https://example.com/design\n" +
+				"Username\n" +
+				"DONE",
+		))
+	})
+	It("Builds documents", func() {
+		doc := NewADFDocument(ADFNode{
+			Type:    "paragraph",
+			Content: []ADFNode{{Type: "text", Text: "Login page"}},
+		})
+
+		Expect(doc.Version).To(Equal(1))
+		Expect(doc.PlainText()).To(Equal("Login page"))
+	})
+})
+
+var _ = Describe("PlainText template function", func() {
+	It("Converts documents", func() {
+		actual, err := formatIssue(
+			testADFDescription,
+			"{{.Fields.Summary | PlainText | FirstWords 2 | KebabCase}}",
+		)
+
+		Expect(actual).To(Equal("login-page"))
+		Expect(err).To(BeNil())
+	})
+	It("Leaves other text alone", func() {
+		actual, err := formatIssue(
+			"Login page",
+			"{{.Fields.Summary | PlainText}}",
+		)
+
+		Expect(actual).To(Equal("Login page"))
+		Expect(err).To(BeNil())
+	})
+})
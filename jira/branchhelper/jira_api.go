@@ -0,0 +1,99 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// The versions of the Jira REST API that can be used. Version 3 is only on
+// Jira Cloud, and uses the Atlassian Document Format for rich text
+const (
+	APIVersion2 = "2"
+	APIVersion3 = "3"
+)
+
+// RequestClient makes requests against the Jira API, as a *jira.Client does
+type RequestClient interface {
+	NewRequest(
+		method string,
+		urlStr string,
+		body interface{},
+	) (*http.Request, error)
+	Do(req *http.Request, v interface{}) (*jira.Response, error)
+}
+
+// contextRequestClient makes every request with a context
+type contextRequestClient struct {
+	ctx    context.Context
+	client RequestClient
+}
+
+// RequestClientWithContext wraps a client so its requests give up when the
+// context is done, for the clients here like TransitionsClient
+func RequestClientWithContext(
+	ctx context.Context,
+	client RequestClient,
+) RequestClient {
+	return contextRequestClient{ctx: ctx, client: client}
+}
+
+func (c contextRequestClient) NewRequest(
+	method string,
+	urlStr string,
+	body interface{},
+) (*http.Request, error) {
+	req, err := c.client.NewRequest(method, urlStr, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return req.WithContext(c.ctx), nil
+}
+
+func (c contextRequestClient) Do(
+	req *http.Request,
+	v interface{},
+) (*jira.Response, error) {
+	return c.client.Do(req, v)
+}
+
+// restAPIPath is where a version of the Jira REST API lives, relative to the
+// endpoint. Version 2 is used if none is given
+func restAPIPath(version string) string {
+	if version == "" {
+		version = APIVersion2
+	}
+
+	return "rest/api/" + version + "/"
+}
+
+func issuePath(version string, issueID string, path string) string {
+	return restAPIPath(version) + "issue/" + url.PathEscape(issueID) + path
+}
+
+// usesADF if a version of the API uses ADF documents for rich text rather than
+// strings
+func usesADF(version string) bool {
+	return version != "" && version != APIVersion2
+}
@@ -0,0 +1,166 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"github.com/pkg/errors"
+)
+
+// User is someone Jira knows about. Jira Cloud identifies users by account ID,
+// Jira Server by name
+type User struct {
+	Name        string `json:"name,omitempty"`
+	Key         string `json:"key,omitempty"`
+	AccountID   string `json:"accountId,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// Same tell if two users are the same person
+func (u User) Same(other User) bool {
+	switch {
+	case u.AccountID != "" && other.AccountID != "":
+		return u.AccountID == other.AccountID
+	case u.Key != "" && other.Key != "":
+		return u.Key == other.Key
+	default:
+		return u.Name != "" && u.Name == other.Name
+	}
+}
+
+// String the name to show users
+func (u User) String() string {
+	for _, name := range []string{u.DisplayName, u.Name, u.AccountID} {
+		if name != "" {
+			return name
+		}
+	}
+
+	return "unknown user"
+}
+
+// AssignClient assigns issues with the Jira API
+type AssignClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// Myself the user we are authenticated as
+func (c AssignClient) Myself() (User, error) {
+	req, err := c.Client.NewRequest(
+		"GET",
+		restAPIPath(c.APIVersion)+"myself",
+		nil,
+	)
+	if err != nil {
+		return User{}, errors.Wrap(err, "failed to build myself request")
+	}
+
+	user := User{}
+	resp, err := c.Client.Do(req, &user)
+
+	if err != nil {
+		return User{}, newRequestError(err, resp)
+	}
+
+	return user, nil
+}
+
+// Assignee the user the issue is assigned to, or nil if it is unassigned
+func (c AssignClient) Assignee(issueID string) (*User, error) {
+	req, err := c.Client.NewRequest(
+		"GET",
+		issuePath(c.APIVersion, issueID, "?fields=assignee"),
+		nil,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build issue request")
+	}
+
+	issue := struct {
+		Fields struct {
+			Assignee *User `json:"assignee"`
+		} `json:"fields"`
+	}{}
+	resp, err := c.Client.Do(req, &issue)
+
+	if err != nil {
+		return nil, newRequestError(err, resp)
+	}
+
+	return issue.Fields.Assignee, nil
+}
+
+// Assign the issue to a user
+func (c AssignClient) Assign(issueID string, user User) error {
+	body := map[string]string{"name": user.Name}
+	if user.AccountID != "" {
+		body = map[string]string{"accountId": user.AccountID}
+	}
+
+	req, err := c.Client.NewRequest(
+		"PUT",
+		issuePath(c.APIVersion, issueID, "/assignee"),
+		body,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to build assign request")
+	}
+
+	if resp, err := c.Client.Do(req, nil); err != nil {
+		return newRequestError(err, resp)
+	}
+
+	return nil
+}
+
+// AssignSelf assign the issue to the user we are authenticated as, unless
+// this is a dry run. Issues assigned to someone else are left alone unless
+// forced. Returns the user the issue is assigned to
+func (c AssignClient) AssignSelf(
+	issueID string,
+	force bool,
+	dryRun bool,
+) (User, error) {
+	myself, err := c.Myself()
+	if err != nil {
+		return User{}, err
+	}
+
+	assignee, err := c.Assignee(issueID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if assignee != nil && assignee.Same(myself) {
+		return myself, nil
+	}
+
+	if assignee != nil && !force {
+		return User{}, errors.Errorf(
+			"issue is already assigned to %s",
+			assignee,
+		)
+	}
+
+	if dryRun {
+		return myself, nil
+	}
+
+	return myself, c.Assign(issueID, myself)
+}
@@ -0,0 +1,137 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AssignClient", func() {
+	var assignee string
+	var assigned []map[string]string
+	var subject AssignClient
+	var stop func()
+
+	BeforeEach(func() {
+		assignee = "null"
+		assigned = []map[string]string{}
+		client, closeServer := testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+
+			switch r.URL.Path {
+			case "/rest/api/2/myself":
+				_, _ = w.Write([]byte(
+					`{"name": "jbloggs", "key": "jbloggs", "displayName": "Joe Bloggs"}`,
+				))
+			case "/rest/api/2/issue/TST-123":
+				Expect(r.URL.Query().Get("fields")).To(Equal("assignee"))
+				_, _ = w.Write([]byte(`{"fields": {"assignee": ` + assignee + `}}`))
+			case "/rest/api/2/issue/TST-123/assignee":
+				Expect(r.Method).To(Equal("PUT"))
+				body := map[string]string{}
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				assigned = append(assigned, body)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		subject = AssignClient{Client: client}
+		stop = closeServer
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("Assigns unassigned issues", func() {
+		actual, err := subject.AssignSelf("TST-123", false, false)
+
+		Expect(err).To(BeNil())
+		Expect(actual.DisplayName).To(Equal("Joe Bloggs"))
+		Expect(assigned).To(Equal([]map[string]string{{"name": "jbloggs"}}))
+	})
+	It("Leaves issues already assigned to us", func() {
+		assignee = `{"name": "jbloggs", "key": "jbloggs"}`
+
+		_, err := subject.AssignSelf("TST-123", false, false)
+
+		Expect(err).To(BeNil())
+		Expect(assigned).To(BeEmpty())
+	})
+	It("Refuses issues assigned to someone else", func() {
+		assignee = `{"name": "asmith", "key": "asmith", "displayName": "Alex Smith"}`
+
+		_, err := subject.AssignSelf("TST-123", false, false)
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Alex Smith"))
+		Expect(assigned).To(BeEmpty())
+	})
+	It("Takes issues assigned to someone else when forced", func() {
+		assignee = `{"name": "asmith", "key": "asmith"}`
+
+		_, err := subject.AssignSelf("TST-123", true, false)
+
+		Expect(err).To(BeNil())
+		Expect(assigned).To(HaveLen(1))
+	})
+	It("Does nothing on a dry run", func() {
+		_, err := subject.AssignSelf("TST-123", false, true)
+
+		Expect(err).To(BeNil())
+		Expect(assigned).To(BeEmpty())
+	})
+})
+
+var _ = Describe("AssignClient on Jira Cloud", func() {
+	It("Assigns by account id when there is one", func() {
+		assigned := map[string]string{}
+		client, stop := testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			Expect(json.NewDecoder(r.Body).Decode(&assigned)).To(Succeed())
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer stop()
+
+		err := AssignClient{Client: client}.Assign(
+			"TST-123",
+			User{Name: "jbloggs", AccountID: "5b10ac8d82e05b22cc7d4ef5"},
+		)
+
+		Expect(err).To(BeNil())
+		Expect(assigned).To(Equal(map[string]string{
+			"accountId": "5b10ac8d82e05b22cc7d4ef5",
+		}))
+	})
+})
+
+var _ = Describe("User", func() {
+	It("Compares by account id, then key, then name", func() {
+		Expect(User{AccountID: "1", Name: "a"}.Same(User{AccountID: "1", Name: "b"})).To(BeTrue())
+		Expect(User{AccountID: "1", Key: "a"}.Same(User{AccountID: "2", Key: "a"})).To(BeFalse())
+		Expect(User{Key: "a", Name: "x"}.Same(User{Key: "a", Name: "y"})).To(BeTrue())
+		Expect(User{Name: "a"}.Same(User{Name: "a"})).To(BeTrue())
+		Expect(User{}.Same(User{})).To(BeFalse())
+	})
+})
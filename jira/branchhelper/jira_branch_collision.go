@@ -0,0 +1,195 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultBranchSuffix is the suffix added to branch names that are taken,
+// giving "tst-1-title-2", "tst-1-title-3" and so on
+const DefaultBranchSuffix = "-{{.Number}}"
+
+// maxBranchSuffixes is how many suffixes are tried before giving up on
+// finding a branch name that isn't taken
+const maxBranchSuffixes = 100
+
+// ExistingBranch is a branch already in the repository, either local or
+// remote-tracking
+type ExistingBranch struct {
+	Name   string
+	Remote string
+}
+
+// String the branch as git shows it, e.g. "origin/tst-1-title"
+func (b ExistingBranch) String() string {
+	if b.Remote == "" {
+		return b.Name
+	}
+
+	return b.Remote + "/" + b.Name
+}
+
+// ParseBranchRefs the branches from full ref names, like the output of
+// "git for-each-ref --format=%(refname) refs/heads refs/remotes". Refs that
+// aren't branches, and the HEAD of remotes, are left out
+func ParseBranchRefs(refs []string) []ExistingBranch {
+	branches := []ExistingBranch{}
+
+	for _, ref := range refs {
+		ref = strings.TrimSpace(ref)
+
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			branches = append(branches, ExistingBranch{
+				Name: strings.TrimPrefix(ref, "refs/heads/"),
+			})
+		case strings.HasPrefix(ref, "refs/remotes/"):
+			parts := strings.SplitN(strings.TrimPrefix(ref, "refs/remotes/"), "/", 2)
+
+			if len(parts) != 2 || parts[1] == "HEAD" {
+				continue
+			}
+
+			branches = append(branches, ExistingBranch{
+				Name:   parts[1],
+				Remote: parts[0],
+			})
+		}
+	}
+
+	return branches
+}
+
+// Initials the lower case initials of a name, e.g. "bat" for "Billie Alice
+// Thompson"
+func Initials(name string) string {
+	initials := []rune{}
+
+	for _, word := range strings.Fields(name) {
+		for _, r := range word {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				initials = append(initials, unicode.ToLower(r))
+				break
+			}
+		}
+	}
+
+	return string(initials)
+}
+
+// BranchSuffixData is what suffix templates can use
+type BranchSuffixData struct {
+	// Branch is the branch name that is taken
+	Branch string
+	// Number counts the suffixes tried, starting at 2
+	Number int
+	// Initials are those of the person making the branch
+	Initials string
+}
+
+// BranchNames finds out if branch names are taken in a repository, and
+// finds ones that aren't
+type BranchNames struct {
+	Existing []ExistingBranch
+	// Suffix is the template for what to add to names that are taken, see
+	// BranchSuffixData for what it can use
+	Suffix   *template.Template
+	Initials string
+}
+
+// Find the branch with the name, preferring a local one to those of remotes
+func (n BranchNames) Find(name string) (ExistingBranch, bool) {
+	var found *ExistingBranch
+
+	for i := range n.Existing {
+		if n.Existing[i].Name != name {
+			continue
+		}
+
+		if n.Existing[i].Remote == "" {
+			return n.Existing[i], true
+		}
+
+		if found == nil {
+			found = &n.Existing[i]
+		}
+	}
+
+	if found == nil {
+		return ExistingBranch{}, false
+	}
+
+	return *found, true
+}
+
+// Unique the name if it isn't taken, otherwise the name with the first
+// suffix that makes it unique
+func (n BranchNames) Unique(name string) (string, error) {
+	if _, taken := n.Find(name); !taken {
+		return name, nil
+	}
+
+	suffix := n.Suffix
+	if suffix == nil {
+		suffix = template.Must(template.New("suffix").Parse(DefaultBranchSuffix))
+	}
+
+	previous := ""
+
+	for number := 2; number < maxBranchSuffixes+2; number++ {
+		buf := &bytes.Buffer{}
+
+		if err := suffix.Execute(buf, BranchSuffixData{
+			Branch:   name,
+			Number:   number,
+			Initials: n.Initials,
+		}); err != nil {
+			return "", errors.Wrap(err, "failed to render branch suffix")
+		}
+
+		candidate := name + buf.String()
+
+		if _, taken := n.Find(candidate); !taken {
+			return candidate, nil
+		}
+
+		if candidate == previous {
+			return "", fmt.Errorf(
+				"branch %q is taken, and the suffix doesn't change, "+
+					"use {{.Number}} in it",
+				candidate,
+			)
+		}
+
+		previous = candidate
+	}
+
+	return "", fmt.Errorf(
+		"no name for %q that isn't taken after %d suffixes",
+		name,
+		maxBranchSuffixes,
+	)
+}
@@ -0,0 +1,128 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"text/template"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseBranchRefs", func() {
+	It("Reads local and remote-tracking branches", func() {
+		Expect(ParseBranchRefs([]string{
+			"refs/heads/master",
+			"refs/heads/feature/tst-1",
+			"refs/remotes/origin/HEAD",
+			"refs/remotes/origin/tst-2-title",
+			"refs/tags/v1.0.0",
+			"",
+		})).To(Equal([]ExistingBranch{
+			{Name: "master"},
+			{Name: "feature/tst-1"},
+			{Name: "tst-2-title", Remote: "origin"},
+		}))
+	})
+})
+
+var _ = Describe("ExistingBranch", func() {
+	It("Shows remote branches with their remote", func() {
+		Expect(ExistingBranch{Name: "tst-1"}.String()).To(Equal("tst-1"))
+		Expect(ExistingBranch{Name: "tst-1", Remote: "origin"}.String()).
+			To(Equal("origin/tst-1"))
+	})
+})
+
+var _ = Describe("Initials", func() {
+	It("Takes the first letter of each word", func() {
+		Expect(Initials("Billie Alice Thompson")).To(Equal("bat"))
+		Expect(Initials("  Ünal  (Bob) o'Neil ")).To(Equal("übo"))
+		Expect(Initials("")).To(Equal(""))
+	})
+})
+
+var _ = Describe("BranchNames", func() {
+	names := BranchNames{
+		Existing: []ExistingBranch{
+			{Name: "tst-1-title", Remote: "origin"},
+			{Name: "tst-1-title"},
+			{Name: "tst-1-title-2", Remote: "upstream"},
+			{Name: "tst-2-title", Remote: "origin"},
+		},
+		Initials: "bat",
+	}
+
+	It("Prefers local branches when finding one", func() {
+		branch, found := names.Find("tst-1-title")
+		Expect(found).To(BeTrue())
+		Expect(branch).To(Equal(ExistingBranch{Name: "tst-1-title"}))
+	})
+
+	It("Finds remote-tracking branches", func() {
+		branch, found := names.Find("tst-2-title")
+		Expect(found).To(BeTrue())
+		Expect(branch.String()).To(Equal("origin/tst-2-title"))
+	})
+
+	It("Leaves names that aren't taken alone", func() {
+		_, found := names.Find("tst-3-title")
+		Expect(found).To(BeFalse())
+		Expect(names.Unique("tst-3-title")).To(Equal("tst-3-title"))
+	})
+
+	It("Counts up until the name is unique", func() {
+		Expect(names.Unique("tst-1-title")).To(Equal("tst-1-title-3"))
+		Expect(names.Unique("tst-2-title")).To(Equal("tst-2-title-2"))
+	})
+
+	It("Uses the suffix template", func() {
+		withInitials := names
+		withInitials.Suffix = template.Must(
+			template.New("suffix").Parse("-{{.Initials}}"),
+		)
+
+		Expect(withInitials.Unique("tst-1-title")).To(Equal("tst-1-title-bat"))
+	})
+
+	It("Fails when the suffix can't make the name unique", func() {
+		withInitials := names
+		withInitials.Existing = append(
+			withInitials.Existing,
+			ExistingBranch{Name: "tst-1-title-bat"},
+		)
+		withInitials.Suffix = template.Must(
+			template.New("suffix").Parse("-{{.Initials}}"),
+		)
+
+		_, err := withInitials.Unique("tst-1-title")
+		Expect(err).To(MatchError(ContainSubstring("use {{.Number}}")))
+	})
+
+	It("Fails when the suffix template does", func() {
+		broken := names
+		broken.Suffix = template.Must(
+			template.New("suffix").Parse("-{{.Missing}}"),
+		)
+
+		_, err := broken.Unique("tst-1-title")
+		Expect(err).To(MatchError(ContainSubstring("failed to render branch suffix")))
+	})
+})
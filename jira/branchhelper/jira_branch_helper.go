@@ -21,7 +21,11 @@ package branchhelper
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
 	"net/http/httputil"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"text/template"
@@ -34,6 +38,8 @@ import (
 // Jira will generate branch names from Jira issues
 type Jira struct {
 	Client GetIssueClient
+	Fields GetFieldsClient
+	Config TemplateConfig
 }
 
 // GetIssueClient allows us to get issues from Jira
@@ -48,6 +54,20 @@ type GetIssueClient interface {
 	)
 }
 
+// GetIssueContextClient allows us to get issues from Jira, giving up when the
+// context is done
+type GetIssueContextClient interface {
+	GetContext(
+		ctx context.Context,
+		issueID string,
+		options *jira.GetQueryOptions,
+	) (
+		*jira.Issue,
+		*jira.Response,
+		error,
+	)
+}
+
 func toSnakeCase(s string) string {
 	unneededCharactersReg, err := regexp.Compile("[^a-zA-Z0-9 ]+")
 
@@ -100,48 +120,222 @@ func normaliseArgument(
 	)
 }
 
-// FormatIssue generate a branch name from a template and a issue ID
-func (helper *Jira) FormatIssue(
-	issueID string,
-	rawTempl string,
-) (string, error) {
-	templ, err := template.New(
+// NewTemplate an empty branch template with the template functions available
+func (helper *Jira) NewTemplate() *template.Template {
+	return template.New(
 		"branch-name",
 	).Funcs(
-		templateFunctions(),
-	).Parse(rawTempl)
+		templateFunctions(helper.Config),
+	)
+}
+
+// ParseTemplate parse a branch template. Templates in files matching the
+// include patterns can be used from it with {{template "name" .}}
+func (helper *Jira) ParseTemplate(
+	rawTempl string,
+	includePatterns ...string,
+) (*template.Template, error) {
+	templ, err := helper.NewTemplate().Parse(rawTempl)
 
 	if err != nil {
-		return "", errors.Wrap(
+		return nil, errors.Wrap(
 			err,
 			"failed to parse branch template",
 		)
 	}
 
-	issue, resp, err := helper.Client.Get(issueID, nil)
-	if err != nil {
-		return "", newRequestError(err, resp)
+	for _, pattern := range includePatterns {
+		// An empty template directory is fine, templates using a partial that
+		// isn't there fail when they are executed instead
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrap(
+				err,
+				"failed to find included templates",
+			)
+		}
+
+		if len(paths) == 0 {
+			continue
+		}
+
+		if _, err := templ.ParseFiles(paths...); err != nil {
+			return nil, errors.Wrap(
+				err,
+				"failed to parse included templates",
+			)
+		}
 	}
 
-	buffer := &bytes.Buffer{}
-	writer := bufio.NewWriter(buffer)
+	return templ, nil
+}
 
-	if err := templ.Execute(writer, issue); err != nil {
-		return "", errors.Wrap(
+// ParseTemplateFile parse a branch template from a file. Templates in files
+// matching the include patterns can be used from it with
+// {{template "name" .}}
+func (helper *Jira) ParseTemplateFile(
+	path string,
+	includePatterns ...string,
+) (*template.Template, error) {
+	rawTempl, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrap(
 			err,
-			"failed to execute branch template",
+			"failed to read branch template",
 		)
 	}
 
-	if err := writer.Flush(); err != nil {
-		return "", errors.Wrap(
-			err,
-			"failed flush template output to buffer",
-		)
+	return helper.ParseTemplate(
+		strings.TrimRight(string(rawTempl), "\r\n"),
+		includePatterns...,
+	)
+}
+
+// FormatIssue generate a branch name from a template and a issue ID
+func (helper *Jira) FormatIssue(
+	issueID string,
+	rawTempl string,
+) (string, error) {
+	return helper.FormatIssueContext(context.Background(), issueID, rawTempl)
+}
+
+// FormatIssueContext generate a branch name from a template and a issue ID,
+// giving up when the context is done
+func (helper *Jira) FormatIssueContext(
+	ctx context.Context,
+	issueID string,
+	rawTempl string,
+) (string, error) {
+	templ, err := helper.ParseTemplate(rawTempl)
+
+	if err != nil {
+		return "", err
+	}
+
+	return helper.FormatIssueTemplateContext(ctx, issueID, templ)
+}
+
+// FormatIssueTemplate generate a branch name from a parsed template and a
+// issue ID
+func (helper *Jira) FormatIssueTemplate(
+	issueID string,
+	templ *template.Template,
+) (string, error) {
+	return helper.FormatIssueTemplateContext(
+		context.Background(),
+		issueID,
+		templ,
+	)
+}
+
+// FormatIssueTemplateContext generate a branch name from a parsed template
+// and a issue ID, giving up when the context is done
+func (helper *Jira) FormatIssueTemplateContext(
+	ctx context.Context,
+	issueID string,
+	templ *template.Template,
+) (string, error) {
+	renderer := helper.NewRenderer(templ)
+	issue, err := helper.getIssue(ctx, issueID, renderer.queryOptions(ctx))
+
+	if err != nil {
+		return "", err
+	}
+
+	return renderer.RenderContext(ctx, issue)
+}
+
+// getIssue get an issue from Jira, giving up when the context is done
+func (helper *Jira) getIssue(
+	ctx context.Context,
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, error) {
+	if helper.Client == nil {
+		return nil, errors.Errorf("no client to get issue %s with", issueID)
+	}
+
+	issue, resp, err := getIssueContext(ctx, helper.Client, issueID, options)
+
+	if err != nil {
+		return nil, newRequestError(err, resp)
 	}
 
-	return buffer.String(), nil
+	return issue, nil
+}
+
+// getIssueContext get an issue from a client, with the context if it supports
+// one. Other clients can't be interrupted, so the context is only checked
+// before asking them
+func getIssueContext(
+	ctx context.Context,
+	client GetIssueClient,
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	if contextClient, ok := client.(GetIssueContextClient); ok {
+		return contextClient.GetContext(ctx, issueID, options)
+	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return client.Get(issueID, options)
+}
+
+// requestError is a failed request to Jira. The message includes the whole
+// request and response, so should only be shown to the user that made it
+type requestError struct {
+	message    string
+	cause      error
+	statusCode int
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// requestStatusCode the status Jira answered a failed request with, zero if
+// it didn't answer or the error isn't from a request
+func requestStatusCode(err error) int {
+	if reqErr, ok := asRequestError(err); ok {
+		return reqErr.statusCode
+	}
+
+	return 0
+}
+
+// requestCause what went wrong with a failed request to Jira, without the
+// request and response, which include the credentials used
+func requestCause(err error) error {
+	if reqErr, ok := asRequestError(err); ok {
+		return reqErr.cause
+	}
+
+	return err
+}
+
+// asRequestError find a failed request to Jira behind an error, following
+// both errors.Wrap and fmt.Errorf("%w"), which templates wrap errors with
+func asRequestError(err error) (*requestError, bool) {
+	for err != nil {
+		if reqErr, ok := err.(*requestError); ok {
+			return reqErr, true
+		}
+
+		switch wrapped := err.(type) {
+		case interface{ Cause() error }:
+			err = wrapped.Cause()
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
 }
 
 func newRequestError(triggerErr error, resp *jira.Response) error {
@@ -177,13 +371,37 @@ func newRequestError(triggerErr error, resp *jira.Response) error {
 		)
 	}
 
-	return errors.New(buffer.String())
+	statusCode := 0
+	if resp != nil && resp.Response != nil {
+		statusCode = resp.StatusCode
+	}
+
+	return &requestError{
+		message:    buffer.String(),
+		cause:      triggerErr,
+		statusCode: statusCode,
+	}
 }
 
 func dumpResponse(resp *jira.Response) (string, error) {
 	respParts := []string{}
 
-	reqDump, err := httputil.DumpRequest(resp.Request, true)
+	// Responses that didn't come from Jira, like a missing issue in a
+	// fixture, have nothing worth showing
+	if resp.Request == nil || resp.Response == nil {
+		return "", nil
+	}
+
+	// Credentials stay out of the dump, wherever it ends up
+	req := *resp.Request
+	req.Header = http.Header{}
+	for name, values := range resp.Request.Header {
+		req.Header[name] = values
+	}
+	req.Header.Del("Authorization")
+	req.Header.Del("Cookie")
+
+	reqDump, err := httputil.DumpRequest(&req, true)
 	if err != nil {
 		return "", errors.Wrap(
 			err,
@@ -208,7 +426,7 @@ func dumpResponse(resp *jira.Response) (string, error) {
 	return fullResp, nil
 }
 
-func templateFunctions() template.FuncMap {
+func templateFunctions(config TemplateConfig) template.FuncMap {
 	return template.FuncMap{
 		"Trim":               trim,
 		"ToLower":            strings.ToLower,
@@ -221,12 +439,44 @@ func templateFunctions() template.FuncMap {
 		"ScreamingSnakeCase": normaliseArgument(varcaser.ScreamingSnakeCase),
 		"UpperCamelCase":     normaliseArgument(varcaser.UpperCamelCase),
 		"UpperKebabCase":     normaliseArgument(varcaser.UpperKebabCase),
+		"RegexReplace":       regexReplace,
+		"RemoveStopWords":    removeStopWords(config),
+		"Abbreviate":         abbreviate(config),
+		"FirstWords":         firstWords,
+		"Default":            defaultValue,
+		"Join":               join,
+		"Split":              split,
+		"Slug":               slug,
+		"PlainText":          plainText,
+		"Field":              unboundFieldFunction,
 	}
 }
 
+// unboundFieldFunction stands in for the Field function while parsing, it is
+// replaced with one that knows about the issue when the template is executed
+func unboundFieldFunction(name string) (string, error) {
+	return "", errors.New("fields can only be looked up for an issue")
+}
+
 // NewJira from a Jira client, build a client helper
 func NewJira(
 	client *jira.Client,
 ) *Jira {
-	return &Jira{Client: client.Issue}
+	return NewJiraWithAPIVersion(client, APIVersion2)
+}
+
+// NewJiraWithAPIVersion from a Jira client, build a client helper that uses a
+// version of the Jira API
+func NewJiraWithAPIVersion(
+	client *jira.Client,
+	version string,
+) *Jira {
+	fields := &CachedFieldsClient{
+		Client: FieldsClient{Client: client, APIVersion: version},
+	}
+
+	return &Jira{
+		Client: IssueClient{Client: client, APIVersion: version},
+		Fields: fields,
+	}
 }
@@ -19,6 +19,12 @@
 package branchhelper_test
 
 import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
 	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
 	"github.com/andygrunwald/go-jira"
 	. "github.com/onsi/ginkgo"
@@ -86,6 +92,128 @@ var _ = Describe("Jira", func() {
 			Expect(err).To(BeNil())
 		})
 	})
+	It("Keeps credentials out of failed requests", func() {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		))
+		defer server.Close()
+		client, err := jira.NewClient(
+			(&jira.BasicAuthTransport{Username: "user", Password: "secret"}).Client(),
+			server.URL+"/",
+		)
+		Expect(err).To(BeNil())
+
+		_, err = NewJira(client).FormatIssue("TST-123", "{{.Key}}")
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("/rest/api/2/issue/TST-123"))
+		Expect(err.Error()).ToNot(ContainSubstring("Authorization"))
+	})
+	Context("Template files", func() {
+		var templateDir string
+
+		BeforeEach(func() {
+			var err error
+			templateDir, err = ioutil.TempDir("", "branch-templates")
+			Expect(err).To(BeNil())
+
+			writeTemplate(
+				templateDir,
+				"prefix.tmpl",
+				"{{define \"prefix\"}}{{.Key | ToLower}}{{end}}",
+			)
+			writeTemplate(
+				templateDir,
+				"branch.tmpl",
+				"{{template \"prefix\" .}}-{{.Fields.Summary | KebabCase}}\n",
+			)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(templateDir)).To(BeNil())
+		})
+
+		It("Parses a template from a file", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplateFile(
+				filepath.Join(templateDir, "branch.tmpl"),
+				filepath.Join(templateDir, "*.tmpl"),
+			)
+			Expect(err).To(BeNil())
+
+			actual, err := subject.FormatIssueTemplate("TST-123", templ)
+
+			Expect(actual).To(Equal("tst-123-phase-1"))
+			Expect(err).To(BeNil())
+		})
+		It("Inline templates can include files", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplate(
+				"{{template \"prefix\" .}}/{{.Fields.Summary | Slug}}",
+				filepath.Join(templateDir, "*.tmpl"),
+			)
+			Expect(err).To(BeNil())
+
+			actual, err := subject.FormatIssueTemplate("TST-123", templ)
+
+			Expect(actual).To(Equal("tst-123/phase-1"))
+			Expect(err).To(BeNil())
+		})
+		It("Missing partials cause an error", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplate("{{template \"prefix\" .}}")
+			Expect(err).To(BeNil())
+
+			actual, err := subject.FormatIssueTemplate("TST-123", templ)
+
+			Expect(actual).To(Equal(""))
+			Expect(err).ToNot(BeNil())
+		})
+		It("Missing files cause an error", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplateFile(
+				filepath.Join(templateDir, "missing.tmpl"),
+			)
+
+			Expect(templ).To(BeNil())
+			Expect(err).ToNot(BeNil())
+		})
+		It("Include patterns matching nothing are skipped", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplate(
+				"{{.Key}}",
+				filepath.Join(templateDir, "*.missing"),
+			)
+			Expect(err).To(BeNil())
+
+			actual, err := subject.FormatIssueTemplate("TST-123", templ)
+
+			Expect(actual).To(Equal("TST-123"))
+			Expect(err).To(BeNil())
+		})
+		It("Partials missing from include patterns fail when used", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplate(
+				"{{template \"prefix\" .}}",
+				filepath.Join(templateDir, "*.missing"),
+			)
+			Expect(err).To(BeNil())
+
+			actual, err := subject.FormatIssueTemplate("TST-123", templ)
+
+			Expect(actual).To(Equal(""))
+			Expect(err).ToNot(BeNil())
+		})
+		It("Broken include patterns cause an error", func() {
+			subject := summaryJira("Phase 1")
+			templ, err := subject.ParseTemplate("{{.Key}}", "[")
+
+			Expect(templ).To(BeNil())
+			Expect(err).ToNot(BeNil())
+		})
+	})
 	It("Lower snake case", func() {
 		actual, err := formatIssue(
 			"Developments Phase 1: Implement Feature γ Bäume",
@@ -152,13 +280,7 @@ var _ = Describe("Jira", func() {
 })
 
 func formatIssue(summary string, templ string) (string, error) {
-	subject := Jira{Client: testGetIssue{
-		issue: &jira.Issue{Fields: &jira.IssueFields{
-			Summary: summary,
-		}},
-		response: nil,
-		err:      nil,
-	}}
+	subject := summaryJira(summary)
 
 	return subject.FormatIssue(
 		"TST-123",
@@ -166,6 +288,34 @@ func formatIssue(summary string, templ string) (string, error) {
 	)
 }
 
+func summaryJira(summary string) Jira {
+	return Jira{Client: testGetIssue{
+		issue: &jira.Issue{
+			Key: "TST-123",
+			Fields: &jira.IssueFields{
+				Summary: summary,
+			},
+		},
+		response: nil,
+		err:      nil,
+	}}
+}
+
+func writeTemplate(dir string, name string, templ string) {
+	err := ioutil.WriteFile(filepath.Join(dir, name), []byte(templ), 0600)
+	Expect(err).To(BeNil())
+}
+
+// testJiraClient a Jira client talking to a local server using the handler,
+// and a function to stop the server
+func testJiraClient(handler http.HandlerFunc) (*jira.Client, func()) {
+	server := httptest.NewServer(handler)
+	client, err := jira.NewClient(nil, server.URL+"/")
+	Expect(err).To(BeNil())
+
+	return client, server.Close
+}
+
 type testGetIssue struct {
 	issue    *jira.Issue
 	response *jira.Response
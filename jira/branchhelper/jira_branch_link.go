@@ -0,0 +1,352 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// remoteLinkApplication identifies links made by this tool, so Jira can
+// recognise them
+const remoteLinkApplication = "jira-branch-helper"
+
+// commentsPageSize how many comments to ask Jira for at once
+const commentsPageSize = 100
+
+// scpLikeURLReg matches git remotes like "git@github.com:org/repo.git"
+var scpLikeURLReg = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):(.+)$`)
+
+// Branch is a branch created for an issue
+type Branch struct {
+	Name          string
+	RepositoryURL string
+}
+
+// URL a link to the branch on the repositories website, or to the repository
+// if the host isn't known
+func (b Branch) URL() string {
+	repoURL, err := url.Parse(b.RepositoryURL)
+	if err != nil || repoURL.Host == "" {
+		return b.RepositoryURL
+	}
+
+	switch {
+	case strings.Contains(repoURL.Host, "github"),
+		strings.Contains(repoURL.Host, "gitlab"):
+		return b.RepositoryURL + "/tree/" + b.Name
+	case strings.Contains(repoURL.Host, "bitbucket"):
+		return b.RepositoryURL + "/branch/" + b.Name
+	}
+
+	return b.RepositoryURL
+}
+
+// Comment the text of a comment announcing the branch
+func (b Branch) Comment() string {
+	if b.RepositoryURL == "" {
+		return fmt.Sprintf("Branch {{%s}} created", b.Name)
+	}
+
+	return fmt.Sprintf(
+		"Branch {{%s}} created in %s",
+		b.Name,
+		b.RepositoryURL,
+	)
+}
+
+// CommentDocument the comment announcing the branch as an ADF document, with
+// the name as code and the repository as a link
+func (b Branch) CommentDocument() ADFNode {
+	paragraph := ADFNode{
+		Type: "paragraph",
+		Content: []ADFNode{
+			{Type: "text", Text: "Branch "},
+			{Type: "text", Text: b.Name, Marks: []ADFMark{{Type: "code"}}},
+			{Type: "text", Text: " created"},
+		},
+	}
+
+	if b.RepositoryURL != "" {
+		paragraph.Content = append(
+			paragraph.Content,
+			ADFNode{Type: "text", Text: " in "},
+			ADFNode{
+				Type: "text",
+				Text: b.RepositoryURL,
+				Marks: []ADFMark{{
+					Type:  "link",
+					Attrs: map[string]interface{}{"href": b.RepositoryURL},
+				}},
+			},
+		)
+	}
+
+	return NewADFDocument(paragraph)
+}
+
+// RepositoryWebURL turn a git remote URL into the URL of the repository on
+// the web, e.g. "git@github.com:org/repo.git" to "https://github.com/org/repo"
+func RepositoryWebURL(remoteURL string) string {
+	remoteURL = strings.TrimSpace(remoteURL)
+
+	if matches := scpLikeURLReg.FindStringSubmatch(remoteURL); matches != nil &&
+		!strings.Contains(remoteURL, "://") {
+		remoteURL = "https://" + matches[1] + "/" + matches[2]
+	}
+
+	parsed, err := url.Parse(remoteURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	if parsed.Scheme != "http" {
+		parsed.Scheme = "https"
+	}
+
+	parsed.User = nil
+	parsed.Host = parsed.Hostname()
+	parsed.Path = strings.TrimSuffix(strings.TrimSuffix(parsed.Path, "/"), ".git")
+
+	return parsed.String()
+}
+
+// RemoteLink is a link from an issue to something outside of Jira
+type RemoteLink struct {
+	ID          int              `json:"id,omitempty"`
+	GlobalID    string           `json:"globalId,omitempty"`
+	Application *LinkApplication `json:"application,omitempty"`
+	Object      RemoteLinkObject `json:"object"`
+}
+
+// LinkApplication is what made a remote link
+type LinkApplication struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// RemoteLinkObject is what a remote link points to
+type RemoteLinkObject struct {
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// Comment is a comment on an issue. Version 3 of the API sends the Document
+// rather than the Body, or the Body as a paragraph if there is no Document
+type Comment struct {
+	ID       string
+	Body     string
+	Document *ADFNode
+}
+
+// apiComment is a comment as the API sees it, with the body either a string
+// or an ADF document
+type apiComment struct {
+	ID   string      `json:"id,omitempty"`
+	Body interface{} `json:"body"`
+}
+
+// LinksClient records branches on issues with the Jira API
+type LinksClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// GetRemoteLinks lists the remote links on an issue
+func (c LinksClient) GetRemoteLinks(issueID string) ([]RemoteLink, error) {
+	links := []RemoteLink{}
+	err := c.do(
+		"GET",
+		issuePath(c.APIVersion, issueID, "/remotelink"),
+		nil,
+		&links,
+	)
+
+	return links, err
+}
+
+// AddRemoteLink adds a remote link to an issue
+func (c LinksClient) AddRemoteLink(issueID string, link RemoteLink) error {
+	return c.do(
+		"POST",
+		issuePath(c.APIVersion, issueID, "/remotelink"),
+		link,
+		nil,
+	)
+}
+
+// GetComments lists the comments on an issue, asking for them a page at a
+// time until Jira has given them all
+func (c LinksClient) GetComments(issueID string) ([]Comment, error) {
+	comments := []Comment{}
+
+	for {
+		result := struct {
+			Comments []apiComment `json:"comments"`
+			Total    int          `json:"total"`
+		}{}
+		err := c.do(
+			"GET",
+			fmt.Sprintf(
+				"%s?startAt=%d&maxResults=%d",
+				issuePath(c.APIVersion, issueID, "/comment"),
+				len(comments),
+				commentsPageSize,
+			),
+			nil,
+			&result,
+		)
+
+		if err != nil {
+			return comments, err
+		}
+
+		for _, comment := range result.Comments {
+			comments = append(
+				comments,
+				Comment{ID: comment.ID, Body: plainText(comment.Body)},
+			)
+		}
+
+		if len(result.Comments) == 0 || len(comments) >= result.Total {
+			return comments, nil
+		}
+	}
+}
+
+// AddComment adds a comment to an issue
+func (c LinksClient) AddComment(issueID string, comment Comment) error {
+	body := apiComment{Body: comment.Body}
+
+	if usesADF(c.APIVersion) {
+		if comment.Document != nil {
+			body.Body = *comment.Document
+		} else {
+			body.Body = NewADFDocument(ADFNode{
+				Type:    "paragraph",
+				Content: []ADFNode{{Type: "text", Text: comment.Body}},
+			})
+		}
+	}
+
+	return c.do(
+		"POST",
+		issuePath(c.APIVersion, issueID, "/comment"),
+		body,
+		nil,
+	)
+}
+
+func (c LinksClient) do(
+	method string,
+	path string,
+	body interface{},
+	v interface{},
+) error {
+	req, err := c.Client.NewRequest(method, path, body)
+	if err != nil {
+		return errors.Wrap(err, "failed to build request")
+	}
+
+	if resp, err := c.Client.Do(req, v); err != nil {
+		return newRequestError(err, resp)
+	}
+
+	return nil
+}
+
+// LinkBranch add a remote link to the branch on the issue, unless this is a
+// dry run or an identical link exists. Returns if a link was needed
+func (c LinksClient) LinkBranch(
+	issueID string,
+	branch Branch,
+	dryRun bool,
+) (bool, error) {
+	if branch.RepositoryURL == "" {
+		return false, errors.New("linking a branch needs a repository url")
+	}
+
+	link := RemoteLink{
+		GlobalID: remoteLinkApplication + ":" + branch.URL(),
+		Application: &LinkApplication{
+			Type: remoteLinkApplication,
+			Name: "Branch",
+		},
+		Object: RemoteLinkObject{
+			URL:     branch.URL(),
+			Title:   "Branch " + branch.Name,
+			Summary: branch.RepositoryURL,
+		},
+	}
+
+	existing, err := c.GetRemoteLinks(issueID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existingLink := range existing {
+		if existingLink.Object.URL == link.Object.URL &&
+			existingLink.Object.Title == link.Object.Title {
+			return false, nil
+		}
+	}
+
+	if dryRun {
+		return true, nil
+	}
+
+	return true, c.AddRemoteLink(issueID, link)
+}
+
+// CommentBranch add a comment about the branch on the issue, unless this is a
+// dry run or an identical comment exists. Returns if a comment was needed
+func (c LinksClient) CommentBranch(
+	issueID string,
+	branch Branch,
+	dryRun bool,
+) (bool, error) {
+	comment := Comment{Body: branch.Comment()}
+
+	if usesADF(c.APIVersion) {
+		document := branch.CommentDocument()
+		comment = Comment{Body: document.PlainText(), Document: &document}
+	}
+
+	existing, err := c.GetComments(issueID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, existingComment := range existing {
+		if strings.TrimSpace(existingComment.Body) == comment.Body {
+			return false, nil
+		}
+	}
+
+	if dryRun {
+		return true, nil
+	}
+
+	return true, c.AddComment(issueID, comment)
+}
@@ -0,0 +1,205 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// searchBatchSize is how many issues are searched for at once, the most Jira
+// returns in one page
+const searchBatchSize = 50
+
+// SearchClient finds issues with the Jira API
+type SearchClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// IssueStatuses the status of each issue, by key, searching for them in
+// batches. Issues that don't exist, or have moved to another key, are left
+// out
+func (c SearchClient) IssueStatuses(keys []string) (map[string]jira.Status, error) {
+	statuses := map[string]jira.Status{}
+
+	for start := 0; start < len(keys); start += searchBatchSize {
+		end := start + searchBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		issues, err := c.search(
+			"key in ("+strings.Join(keys[start:end], ", ")+")",
+			[]string{"status"},
+			end-start,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, issue := range issues {
+			if issue.Fields != nil && issue.Fields.Status != nil {
+				statuses[issue.Key] = *issue.Fields.Status
+			}
+		}
+	}
+
+	return statuses, nil
+}
+
+// search the issues matching the JQL. Keys in it that don't exist are
+// ignored, rather than failing the search
+func (c SearchClient) search(
+	jql string,
+	fields []string,
+	maxResults int,
+) ([]jira.Issue, error) {
+	body := map[string]interface{}{
+		"jql":           jql,
+		"fields":        fields,
+		"maxResults":    maxResults,
+		"validateQuery": "warn",
+	}
+	req, err := c.Client.NewRequest("POST", restAPIPath(c.APIVersion)+"search", body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build search request")
+	}
+
+	result := struct {
+		Issues []jira.Issue `json:"issues"`
+	}{}
+	resp, err := c.Client.Do(req, &result)
+
+	if err != nil {
+		return nil, newRequestError(err, resp)
+	}
+
+	return result.Issues, nil
+}
+
+// StaleBranch is a branch for an issue that is finished
+type StaleBranch struct {
+	Branch ExistingBranch
+	Key    string
+	Status string
+}
+
+// PrunePolicy is which branches can be pruned
+type PrunePolicy struct {
+	// MainBranch is the branch the others are merged into, e.g. "master" or
+	// "origin/main". It is never pruned, locally or on any remote
+	MainBranch string
+	// CheckedOut is the local branch checked out, which is never pruned
+	CheckedOut string
+	// Remote prunes branches on remotes, as well as local branches
+	Remote bool
+	// Projects are the keys of the projects branches can be pruned for.
+	// Names like "fix-utf-8" or "release-1.2" look like they have issue
+	// keys in them, so only keys from these projects are trusted, and no
+	// branches are pruned if there are none
+	Projects []string
+	// Statuses are the names of the statuses of finished issues. If empty
+	// issues are finished when they are done
+	Statuses []string
+}
+
+// BranchIssueKeys the keys of the issues of the branches that can be pruned,
+// each once, in order
+func (p PrunePolicy) BranchIssueKeys(branches []ExistingBranch) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+
+	for _, branch := range branches {
+		key, found := p.issueKey(branch)
+		if !found || seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// StaleBranches the branches that can be pruned whose issues are finished
+func (p PrunePolicy) StaleBranches(
+	branches []ExistingBranch,
+	issueStatuses map[string]jira.Status,
+) []StaleBranch {
+	stale := []StaleBranch{}
+
+	for _, branch := range branches {
+		key, found := p.issueKey(branch)
+		if !found {
+			continue
+		}
+
+		status, found := issueStatuses[key]
+		if !found {
+			continue
+		}
+
+		finished := status.StatusCategory.Key == statusCategoryDone
+		if len(p.Statuses) > 0 {
+			finished = inStatuses(status, p.Statuses)
+		}
+
+		if finished {
+			stale = append(stale, StaleBranch{
+				Branch: branch,
+				Key:    key,
+				Status: status.Name,
+			})
+		}
+	}
+
+	return stale
+}
+
+// issueKey the key of the issue a branch is for, if the branch can be pruned
+func (p PrunePolicy) issueKey(branch ExistingBranch) (string, bool) {
+	if p.protected(branch) || (branch.Remote != "" && !p.Remote) {
+		return "", false
+	}
+
+	key, found := IssueKeyFromBranch(branch.Name)
+	if !found {
+		return "", false
+	}
+
+	project := key[:strings.LastIndex(key, "-")]
+
+	return key, containsFold(p.Projects, project)
+}
+
+// protected if a branch is the main branch, locally or on a remote, or the
+// branch checked out
+func (p PrunePolicy) protected(branch ExistingBranch) bool {
+	return branch.String() == p.MainBranch ||
+		branch.Name == p.MainBranch ||
+		strings.HasSuffix(p.MainBranch, "/"+branch.Name) ||
+		(branch.Remote == "" && branch.Name == p.CheckedOut)
+}
@@ -0,0 +1,187 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"fmt"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper/branchhelpertest"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SearchClient", func() {
+	var server *branchhelpertest.Server
+	var client SearchClient
+
+	BeforeEach(func() {
+		server = branchhelpertest.NewServer()
+		server.AddIssue("TST-1", map[string]interface{}{
+			"summary": "Login page",
+			"status":  map[string]interface{}{"id": "3", "name": "In Progress"},
+		})
+		server.AddIssue("TST-2", map[string]interface{}{
+			"summary": "Logout page",
+			"status":  map[string]interface{}{"id": "5", "name": "Done"},
+		})
+
+		jiraClient, err := jira.NewClient(nil, server.URL)
+		Expect(err).To(BeNil())
+		client = SearchClient{Client: jiraClient}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Gets the statuses of issues", func() {
+		server.SetSearchResults("key in (TST-1, TST-2, TST-3)", "TST-1", "TST-2")
+
+		statuses, err := client.IssueStatuses([]string{"TST-1", "TST-2", "TST-3"})
+		Expect(err).To(BeNil())
+		Expect(statuses).To(HaveLen(2))
+		Expect(statuses["TST-1"].Name).To(Equal("In Progress"))
+		Expect(statuses["TST-2"].Name).To(Equal("Done"))
+
+		requests := server.Requests()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Method).To(Equal("POST"))
+		Expect(requests[0].Body).To(ContainSubstring(`"validateQuery":"warn"`))
+	})
+
+	It("Searches in batches", func() {
+		keys := []string{}
+		for i := 1; i <= 120; i++ {
+			keys = append(keys, fmt.Sprintf("TST-%d", i))
+		}
+
+		_, err := client.IssueStatuses(keys)
+		Expect(err).To(BeNil())
+		Expect(server.Requests()).To(HaveLen(3))
+	})
+
+	It("Doesn't search for nothing", func() {
+		statuses, err := client.IssueStatuses([]string{})
+		Expect(err).To(BeNil())
+		Expect(statuses).To(BeEmpty())
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("Fails when Jira does", func() {
+		server.Fail("search", 500, 0)
+
+		_, err := client.IssueStatuses([]string{"TST-1"})
+		Expect(err).ToNot(BeNil())
+	})
+})
+
+var _ = Describe("PrunePolicy", func() {
+	branches := []ExistingBranch{
+		{Name: "tst-1-login-page"},
+		{Name: "tst-2-logout-page"},
+		{Name: "feature/tst-2-logout-page", Remote: "origin"},
+		{Name: "tst-3-gone"},
+		{Name: "master"},
+	}
+	done := jira.Status{ID: "5", Name: "Done"}
+	done.StatusCategory.Key = "done"
+	statuses := map[string]jira.Status{
+		"TST-1": {ID: "3", Name: "In Progress"},
+		"TST-2": done,
+	}
+	var subject PrunePolicy
+
+	BeforeEach(func() {
+		subject = PrunePolicy{
+			MainBranch: "master",
+			Remote:     true,
+			Projects:   []string{"TST"},
+		}
+	})
+
+	It("Finds the keys of the issues of branches", func() {
+		Expect(subject.BranchIssueKeys(branches)).
+			To(Equal([]string{"TST-1", "TST-2", "TST-3"}))
+	})
+	It("Finds branches for issues that are done", func() {
+		Expect(subject.StaleBranches(branches, statuses)).To(Equal([]StaleBranch{
+			{Branch: branches[1], Key: "TST-2", Status: "Done"},
+			{Branch: branches[2], Key: "TST-2", Status: "Done"},
+		}))
+	})
+	It("Finds branches for issues in the statuses given", func() {
+		subject.Statuses = []string{"in progress"}
+
+		Expect(subject.StaleBranches(branches, statuses)).To(Equal([]StaleBranch{
+			{Branch: branches[0], Key: "TST-1", Status: "In Progress"},
+		}))
+	})
+	It("Only prunes local branches unless asked to", func() {
+		subject.Remote = false
+
+		Expect(subject.StaleBranches(branches, statuses)).To(Equal([]StaleBranch{
+			{Branch: branches[1], Key: "TST-2", Status: "Done"},
+		}))
+	})
+	It("Never prunes the branch checked out", func() {
+		subject.CheckedOut = "tst-2-logout-page"
+
+		Expect(subject.StaleBranches(branches, statuses)).To(Equal([]StaleBranch{
+			{Branch: branches[2], Key: "TST-2", Status: "Done"},
+		}))
+	})
+	It("Never prunes the main branch", func() {
+		main := []ExistingBranch{
+			{Name: "tst-2-release"},
+			{Name: "tst-2-release", Remote: "origin"},
+			{Name: "tst-2-release", Remote: "upstream"},
+		}
+
+		for _, mainBranch := range []string{"tst-2-release", "origin/tst-2-release"} {
+			subject.MainBranch = mainBranch
+
+			Expect(subject.StaleBranches(main, statuses)).To(BeEmpty())
+		}
+	})
+	It("Only trusts keys from the projects given", func() {
+		subject.Projects = []string{"tst"}
+		lookalikes := []ExistingBranch{
+			{Name: "fix-utf-8-encoding"},
+			{Name: "release-1.2"},
+			{Name: "ops-12-deploy"},
+			{Name: "tst-2-logout-page"},
+		}
+
+		Expect(subject.BranchIssueKeys(lookalikes)).To(Equal([]string{"TST-2"}))
+		Expect(subject.StaleBranches(
+			lookalikes,
+			map[string]jira.Status{"UTF-8": done, "RELEASE-1": done, "TST-2": done},
+		)).To(Equal([]StaleBranch{
+			{Branch: lookalikes[3], Key: "TST-2", Status: "Done"},
+		}))
+	})
+	It("Prunes nothing without projects", func() {
+		subject.Projects = nil
+
+		Expect(subject.BranchIssueKeys(branches)).To(BeEmpty())
+		Expect(subject.StaleBranches(branches, statuses)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,222 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// Results of checking a branch against a BranchPolicy
+const (
+	VerifyOK                = "ok"
+	VerifyNoIssueKey        = "no-issue-key"
+	VerifyIssueNotFound     = "issue-not-found"
+	VerifyProjectNotAllowed = "project-not-allowed"
+	VerifyStatusNotAllowed  = "status-not-allowed"
+	VerifyNameMismatch      = "name-mismatch"
+)
+
+// statusCategoryDone is the key of the status category of finished issues
+const statusCategoryDone = "done"
+
+// verifyFields are the fields needed to check an issue against a policy
+const verifyFields = "project,status"
+
+// branchIssueKeyReg matches issue keys in branch names, which are often
+// lower cased, like "feature/tst-123-login-page"
+var branchIssueKeyReg = regexp.MustCompile(
+	`(?:^|[^A-Za-z0-9])([A-Za-z][A-Za-z0-9_]*-[0-9]+)(?:[^0-9]|$)`,
+)
+
+// IssueKeyFromBranch the first issue key in a branch name, upper cased as
+// Jira has it
+func IssueKeyFromBranch(branch string) (string, bool) {
+	matches := branchIssueKeyReg.FindStringSubmatch(branch)
+	if matches == nil {
+		return "", false
+	}
+
+	return strings.ToUpper(matches[1]), true
+}
+
+// BranchPolicy is what a branch name has to follow
+type BranchPolicy struct {
+	// Projects are the keys of the projects issues can be from, any project
+	// if empty
+	Projects []string
+	// Statuses are the names of the statuses issues can be in. If empty
+	// issues can be in any status that isn't done
+	Statuses []string
+	// Template is what the branch name must match when rendered for the
+	// issue, if set
+	Template *template.Template
+}
+
+// BranchVerification is the result of checking a branch against a policy
+type BranchVerification struct {
+	Branch   string `json:"branch"`
+	Key      string `json:"key,omitempty"`
+	Project  string `json:"project,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	Result   string `json:"result"`
+	Message  string `json:"message"`
+}
+
+// OK whether the branch follows the policy
+func (v BranchVerification) OK() bool {
+	return v.Result == VerifyOK
+}
+
+// VerifyBranch check a branch name references an issue that follows the
+// policy. Branches that don't are reported in the result, errors are only for
+// when Jira couldn't be asked
+func (helper *Jira) VerifyBranch(
+	ctx context.Context,
+	branch string,
+	policy BranchPolicy,
+) (BranchVerification, error) {
+	result := BranchVerification{Branch: branch}
+
+	key, found := IssueKeyFromBranch(branch)
+	if !found {
+		return result.report(VerifyNoIssueKey, "no issue key in %q", branch), nil
+	}
+
+	result.Key = key
+
+	var renderer *Renderer
+	options := &jira.GetQueryOptions{Fields: verifyFields}
+
+	if policy.Template != nil {
+		renderer = helper.NewRenderer(policy.Template)
+
+		if options = renderer.queryOptions(ctx); options != nil {
+			options.Fields += "," + verifyFields
+		}
+	}
+
+	issue, err := helper.getIssue(ctx, key, options)
+
+	if requestStatusCode(err) == http.StatusNotFound {
+		return result.report(VerifyIssueNotFound, "%s doesn't exist", key), nil
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Key = issue.Key
+	result.Project = issueProject(issue)
+
+	if issue.Fields != nil && issue.Fields.Status != nil {
+		result.Status = issue.Fields.Status.Name
+	}
+
+	if len(policy.Projects) > 0 && !containsFold(policy.Projects, result.Project) {
+		return result.report(
+			VerifyProjectNotAllowed,
+			"%s isn't in the projects %s",
+			issue.Key,
+			strings.Join(policy.Projects, ", "),
+		), nil
+	}
+
+	if !statusAllowed(issue, policy.Statuses) {
+		return result.report(
+			VerifyStatusNotAllowed,
+			"%s is %s",
+			issue.Key,
+			result.Status,
+		), nil
+	}
+
+	if renderer != nil {
+		result.Expected, err = renderer.RenderContext(ctx, issue)
+
+		if err != nil {
+			return result, err
+		}
+
+		if result.Expected != branch {
+			return result.report(
+				VerifyNameMismatch,
+				"%q should be %q",
+				branch,
+				result.Expected,
+			), nil
+		}
+	}
+
+	return result.report(VerifyOK, "%s references %s", branch, issue.Key), nil
+}
+
+// report the result of the verification, with a message explaining it
+func (v BranchVerification) report(
+	result string,
+	format string,
+	args ...interface{},
+) BranchVerification {
+	v.Result = result
+	v.Message = fmt.Sprintf(format, args...)
+
+	return v
+}
+
+// statusAllowed whether the issue is in one of the statuses, or isn't done if
+// no statuses are given
+func statusAllowed(issue *jira.Issue, statuses []string) bool {
+	if issue.Fields == nil || issue.Fields.Status == nil {
+		return len(statuses) == 0
+	}
+
+	status := issue.Fields.Status
+
+	if len(statuses) == 0 {
+		return status.StatusCategory.Key != statusCategoryDone
+	}
+
+	return inStatuses(*status, statuses)
+}
+
+// inStatuses whether the status is one of those named, by name or ID
+func inStatuses(status jira.Status, statuses []string) bool {
+	return containsFold(statuses, status.Name) || containsFold(statuses, status.ID)
+}
+
+// issueProject the key of the project the issue is in, from its key if Jira
+// didn't give the project
+func issueProject(issue *jira.Issue) string {
+	if issue.Fields != nil && issue.Fields.Project.Key != "" {
+		return issue.Fields.Project.Key
+	}
+
+	if index := strings.LastIndex(issue.Key, "-"); index > 0 {
+		return issue.Key[:index]
+	}
+
+	return ""
+}
@@ -0,0 +1,168 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper/branchhelpertest"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IssueKeyFromBranch", func() {
+	branches := map[string]string{
+		"tst-123-login-page":         "TST-123",
+		"feature/TST-123-login-page": "TST-123",
+		"feature/tst-123":            "TST-123",
+		"web_2-5/tst-1-title-2":      "WEB_2-5",
+		"bugfix-tst-9":               "TST-9",
+	}
+
+	for branch, expected := range branches {
+		branch, expected := branch, expected
+
+		It("Finds the key in "+branch, func() {
+			key, found := IssueKeyFromBranch(branch)
+			Expect(found).To(BeTrue())
+			Expect(key).To(Equal(expected))
+		})
+	}
+
+	It("Finds nothing in branches without a key", func() {
+		_, found := IssueKeyFromBranch("master")
+		Expect(found).To(BeFalse())
+
+		_, found = IssueKeyFromBranch("hotfix/login-page")
+		Expect(found).To(BeFalse())
+	})
+})
+
+var _ = Describe("VerifyBranch", func() {
+	var server *branchhelpertest.Server
+	var helper *Jira
+
+	status := func(name string, category string) map[string]interface{} {
+		return map[string]interface{}{
+			"id":             name,
+			"name":           name,
+			"statusCategory": map[string]interface{}{"key": category},
+		}
+	}
+
+	BeforeEach(func() {
+		server = branchhelpertest.NewServer()
+		server.AddIssue("TST-123", map[string]interface{}{
+			"summary": "Implement the login page",
+			"project": map[string]interface{}{"key": "TST"},
+			"status":  status("In Progress", "indeterminate"),
+		})
+		server.AddIssue("TST-124", map[string]interface{}{
+			"summary": "Old work",
+			"project": map[string]interface{}{"key": "TST"},
+			"status":  status("Done", "done"),
+		})
+
+		client, err := jira.NewClient(nil, server.URL)
+		Expect(err).To(BeNil())
+		helper = NewJira(client)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	verify := func(branch string, policy BranchPolicy) BranchVerification {
+		result, err := helper.VerifyBranch(context.Background(), branch, policy)
+		Expect(err).To(BeNil())
+
+		return result
+	}
+
+	It("Passes branches for open issues", func() {
+		result := verify("feature/tst-123-login", BranchPolicy{})
+
+		Expect(result.OK()).To(BeTrue())
+		Expect(result).To(Equal(BranchVerification{
+			Branch:  "feature/tst-123-login",
+			Key:     "TST-123",
+			Project: "TST",
+			Status:  "In Progress",
+			Result:  VerifyOK,
+			Message: "feature/tst-123-login references TST-123",
+		}))
+	})
+
+	It("Fails branches without an issue key", func() {
+		result := verify("master", BranchPolicy{})
+
+		Expect(result.OK()).To(BeFalse())
+		Expect(result.Result).To(Equal(VerifyNoIssueKey))
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("Fails branches for issues that don't exist", func() {
+		Expect(verify("tst-999-nope", BranchPolicy{}).Result).
+			To(Equal(VerifyIssueNotFound))
+	})
+
+	It("Fails branches for issues that are done", func() {
+		result := verify("tst-124-old-work", BranchPolicy{})
+
+		Expect(result.Result).To(Equal(VerifyStatusNotAllowed))
+		Expect(result.Message).To(Equal("TST-124 is Done"))
+	})
+
+	It("Only allows the statuses given", func() {
+		policy := BranchPolicy{Statuses: []string{"to do", "done"}}
+
+		Expect(verify("tst-123-login", policy).Result).
+			To(Equal(VerifyStatusNotAllowed))
+		Expect(verify("tst-124-old-work", policy).Result).To(Equal(VerifyOK))
+	})
+
+	It("Only allows the projects given", func() {
+		Expect(verify("tst-123-login", BranchPolicy{Projects: []string{"WEB"}}).Result).
+			To(Equal(VerifyProjectNotAllowed))
+		Expect(verify("tst-123-login", BranchPolicy{Projects: []string{"tst"}}).Result).
+			To(Equal(VerifyOK))
+	})
+
+	It("Checks the branch matches the template", func() {
+		templ, err := helper.ParseTemplate("{{.Key | ToLower}}-{{.Fields.Summary | Slug}}")
+		Expect(err).To(BeNil())
+		policy := BranchPolicy{Template: templ}
+
+		result := verify("tst-123-login", policy)
+		Expect(result.Result).To(Equal(VerifyNameMismatch))
+		Expect(result.Expected).To(Equal("tst-123-implement-the-login-page"))
+
+		Expect(verify("tst-123-implement-the-login-page", policy).Result).
+			To(Equal(VerifyOK))
+	})
+
+	It("Fails when Jira does", func() {
+		server.Fail("issue/TST-123", 500, 0)
+
+		_, err := helper.VerifyBranch(context.Background(), "tst-123", BranchPolicy{})
+		Expect(err).ToNot(BeNil())
+	})
+})
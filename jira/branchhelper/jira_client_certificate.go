@@ -0,0 +1,111 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// loadClientCertificate reads the client certificate and its key, decrypting
+// the key if it has a passphrase
+func loadClientCertificate(options HTTPClientOptions) (tls.Certificate, error) {
+	certPEM, err := ioutil.ReadFile(options.ClientCertFile)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(
+			err,
+			"failed to read client certificate",
+		)
+	}
+
+	keyPEM := certPEM
+
+	if options.ClientKeyFile != "" {
+		keyPEM, err = ioutil.ReadFile(options.ClientKeyFile)
+		if err != nil {
+			return tls.Certificate{}, errors.Wrap(
+				err,
+				"failed to read client key",
+			)
+		}
+	}
+
+	keyPEM, err = decryptKey(keyPEM, options.ClientKeyPassphrase)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(
+			err,
+			"failed to load client certificate",
+		)
+	}
+
+	return certificate, nil
+}
+
+// decryptKey find the private key in some PEM, decrypting it with the
+// passphrase if it is encrypted
+func decryptKey(
+	keyPEM []byte,
+	passphrase func() ([]byte, error),
+) ([]byte, error) {
+	for rest := keyPEM; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+
+		if block == nil {
+			return keyPEM, nil
+		}
+
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, errors.New(
+				"encrypted PKCS#8 client keys are not supported, " +
+					"encrypt it in the traditional PEM format, e.g. " +
+					"with \"openssl rsa -aes256 -traditional\"",
+			)
+		}
+
+		if !x509.IsEncryptedPEMBlock(block) {
+			continue
+		}
+
+		if passphrase == nil {
+			return nil, errors.New("client key is encrypted, but no passphrase given")
+		}
+
+		password, err := passphrase()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get client key passphrase")
+		}
+
+		der, err := x509.DecryptPEMBlock(block, password)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to decrypt client key")
+		}
+
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+}
@@ -0,0 +1,209 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client certificates", func() {
+	var server *httptest.Server
+	var dir string
+	var certFile string
+	var keyDER []byte
+	var clientName string
+
+	writePEM := func(name string, block *pem.Block) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600)).To(Succeed())
+
+		return path
+	}
+
+	options := func(keyFile string) HTTPClientOptions {
+		options := DefaultHTTPClientOptions()
+		options.InsecureSkipVerify = true
+		options.ClientCertFile = certFile
+		options.ClientKeyFile = keyFile
+
+		return options
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "jira-branch-helper")
+		Expect(err).To(BeNil())
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).To(BeNil())
+		keyDER, err = x509.MarshalECPrivateKey(key)
+		Expect(err).To(BeNil())
+
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "jira-branch-helper"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+			ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+		certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		Expect(err).To(BeNil())
+		certFile = writePEM("client.pem", &pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+		certificate, err := x509.ParseCertificate(certDER)
+		Expect(err).To(BeNil())
+		clientCAs := x509.NewCertPool()
+		clientCAs.AddCert(certificate)
+
+		clientName = ""
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientName = r.TLS.PeerCertificates[0].Subject.CommonName
+
+			if r.URL.Path == "/rest/auth/1/session" {
+				http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "1"})
+				_, _ = w.Write([]byte(`{"session": {"name": "JSESSIONID", "value": "1"}}`))
+			}
+		}))
+		server.TLS = &tls.Config{
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			ClientCAs:  clientCAs,
+		}
+		server.StartTLS()
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("Are needed by the server", func() {
+		client, err := NewHTTPClient(options(""))
+		Expect(err).ToNot(BeNil())
+		Expect(client).To(BeNil())
+
+		client, err = NewHTTPClient(DefaultHTTPClientOptions())
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL)
+		Expect(err).ToNot(BeNil())
+	})
+	It("Identify us to the server", func() {
+		keyFile := writePEM("client.key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		client, err := NewHTTPClient(options(keyFile))
+		Expect(err).To(BeNil())
+
+		resp, err := client.Get(server.URL)
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(clientName).To(Equal("jira-branch-helper"))
+	})
+	It("Can have the key in the same file", func() {
+		certPEM, err := ioutil.ReadFile(certFile)
+		Expect(err).To(BeNil())
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		Expect(ioutil.WriteFile(certFile, append(certPEM, keyPEM...), 0600)).To(Succeed())
+
+		client, err := NewHTTPClient(options(""))
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL)
+		Expect(err).To(BeNil())
+	})
+	It("Are used when logging in", func() {
+		keyFile := writePEM("client.key", &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+		httpClient, err := NewHTTPClient(options(keyFile))
+		Expect(err).To(BeNil())
+
+		client, err := jira.NewClient(httpClient, server.URL+"/")
+		Expect(err).To(BeNil())
+
+		_, err = client.Authentication.AcquireSessionCookie("user", "password")
+		Expect(err).To(BeNil())
+		Expect(clientName).To(Equal("jira-branch-helper"))
+	})
+	Context("With an encrypted key", func() {
+		var keyFile string
+		var asked int
+
+		passphrase := func(value string) func() ([]byte, error) {
+			return func() ([]byte, error) {
+				asked++
+
+				return []byte(value), nil
+			}
+		}
+
+		BeforeEach(func() {
+			asked = 0
+			block, err := x509.EncryptPEMBlock(
+				rand.Reader,
+				"EC PRIVATE KEY",
+				keyDER,
+				[]byte("secret"),
+				x509.PEMCipherAES256,
+			)
+			Expect(err).To(BeNil())
+			keyFile = writePEM("client.key", block)
+		})
+
+		It("Asks for the passphrase", func() {
+			options := options(keyFile)
+			options.ClientKeyPassphrase = passphrase("secret")
+			client, err := NewHTTPClient(options)
+			Expect(err).To(BeNil())
+			Expect(asked).To(Equal(1))
+
+			_, err = client.Get(server.URL)
+			Expect(err).To(BeNil())
+		})
+		It("Fails with the wrong passphrase", func() {
+			options := options(keyFile)
+			options.ClientKeyPassphrase = passphrase("wrong")
+			_, err := NewHTTPClient(options)
+
+			Expect(err).ToNot(BeNil())
+		})
+		It("Fails without a passphrase", func() {
+			_, err := NewHTTPClient(options(keyFile))
+
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
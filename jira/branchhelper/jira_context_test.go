@@ -0,0 +1,112 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Contexts", func() {
+	var requests int
+	var delay time.Duration
+	var client *jira.Client
+	var stop func()
+
+	BeforeEach(func() {
+		requests = 0
+		delay = 0
+		client, stop = testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			time.Sleep(delay)
+			w.Header().Set("Content-Type", "application/json")
+
+			switch r.URL.Path {
+			case "/rest/api/2/issue/TST-123":
+				_, _ = w.Write([]byte(`{"key": "TST-123", "fields": {"summary": "Login page"}}`))
+			case "/rest/api/2/issue/TST-123/transitions":
+				_, _ = w.Write([]byte(`{"transitions": []}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("Formats issues", func() {
+		actual, err := NewJira(client).FormatIssueContext(
+			context.Background(),
+			"TST-123",
+			"{{.Key}}-{{.Fields.Summary | KebabCase}}",
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("TST-123-login-page"))
+	})
+	It("Stops when cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := NewJira(client).FormatIssueContext(ctx, "TST-123", "{{.Key}}")
+
+		Expect(err).ToNot(BeNil())
+		Expect(requests).To(Equal(0))
+	})
+	It("Gives up after the deadline", func() {
+		delay = 200 * time.Millisecond
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := NewJira(client).FormatIssueContext(ctx, "TST-123", "{{.Key}}")
+
+		Expect(err).ToNot(BeNil())
+		Expect(time.Since(start)).To(BeNumerically("<", delay))
+	})
+	It("Does not ask clients without contexts once cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		subject := summaryJira("Login page")
+		_, err := subject.FormatIssueContext(ctx, "TST-123", "{{.Key}}")
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Can be used with the other clients", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		transitions := TransitionsClient{Client: RequestClientWithContext(ctx, client)}
+		_, err := transitions.GetTransitions("TST-123")
+		Expect(err).To(BeNil())
+
+		cancel()
+		_, err = transitions.GetTransitions("TST-123")
+		Expect(err).ToNot(BeNil())
+		Expect(requests).To(Equal(1))
+	})
+})
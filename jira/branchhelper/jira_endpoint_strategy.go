@@ -75,19 +75,43 @@ func makeURL(sourceURL *url.URL, path string) *url.URL {
 	}
 }
 
-// GuessEndpointURL Guesses the endpoint URL from a issue URL
-func GuessEndpointURL(issueURL *url.URL) string {
-	if issueURL == nil {
-		return ""
-	}
+// EndpointGuesser tries each of its strategies in the order they were
+// registered, using the first endpoint URL found
+type EndpointGuesser struct {
+	strategies []EndpointStrategy
+}
 
-	urlHelpers := []EndpointStrategy{
+// NewEndpointGuesser build a guesser that tries the given strategies in order
+func NewEndpointGuesser(strategies ...EndpointStrategy) *EndpointGuesser {
+	return &EndpointGuesser{strategies: strategies}
+}
+
+// DefaultEndpointGuesser build a guesser with the built in strategies
+func DefaultEndpointGuesser() *EndpointGuesser {
+	return NewEndpointGuesser(
 		EndpointCombinedStrategy{},
 		EndpointSoloStrategy{},
+	)
+}
+
+// Register add a strategy to try after the ones already registered
+func (g *EndpointGuesser) Register(strategy EndpointStrategy) {
+	g.strategies = append(g.strategies, strategy)
+}
+
+// RegisterFirst add a strategy to try before the ones already registered
+func (g *EndpointGuesser) RegisterFirst(strategy EndpointStrategy) {
+	g.strategies = append([]EndpointStrategy{strategy}, g.strategies...)
+}
+
+// Guess the endpoint URL from a issue URL
+func (g *EndpointGuesser) Guess(issueURL *url.URL) string {
+	if issueURL == nil {
+		return ""
 	}
 
-	for i := range urlHelpers {
-		possibleEndpointURL := urlHelpers[i].GetEndpoint(issueURL)
+	for i := range g.strategies {
+		possibleEndpointURL := g.strategies[i].GetEndpoint(issueURL)
 
 		if possibleEndpointURL != nil {
 			return possibleEndpointURL.String()
@@ -96,3 +120,9 @@ func GuessEndpointURL(issueURL *url.URL) string {
 
 	return ""
 }
+
+// GuessEndpointURL Guesses the endpoint URL from a issue URL using the built in
+// strategies
+func GuessEndpointURL(issueURL *url.URL) string {
+	return DefaultEndpointGuesser().Guess(issueURL)
+}
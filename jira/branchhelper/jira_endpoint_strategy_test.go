@@ -100,3 +100,50 @@ var _ = Describe("Guess endpoint url", func() {
 		})
 	})
 })
+
+var _ = Describe("EndpointGuesser", func() {
+	Context("Failure", func() {
+		It("Returns empty when no strategy matches", func() {
+			url, _ := url.Parse("https://example.com/tracker/TST-101")
+
+			actual := DefaultEndpointGuesser().Guess(url)
+			Expect(actual).To(Equal(""))
+		})
+		It("Returns empty with no strategies", func() {
+			url, _ := url.Parse("https://example.com/browse/TST-101")
+
+			actual := NewEndpointGuesser().Guess(url)
+			Expect(actual).To(Equal(""))
+		})
+	})
+	Context("Success", func() {
+		It("Uses registered strategies after the defaults", func() {
+			guesser := DefaultEndpointGuesser()
+			guesser.Register(testEndpointStrategy{path: "/tracker"})
+			url, _ := url.Parse("https://example.com/tracker/TST-101")
+
+			actual := guesser.Guess(url)
+			Expect(actual).To(Equal("https://example.com/tracker"))
+		})
+		It("Registered strategies can take priority", func() {
+			guesser := DefaultEndpointGuesser()
+			guesser.RegisterFirst(testEndpointStrategy{path: "/tracker"})
+			url, _ := url.Parse("https://example.com/browse/TST-101")
+
+			actual := guesser.Guess(url)
+			Expect(actual).To(Equal("https://example.com/tracker"))
+		})
+	})
+})
+
+type testEndpointStrategy struct {
+	path string
+}
+
+func (t testEndpointStrategy) GetEndpoint(issueURL *url.URL) *url.URL {
+	return &url.URL{
+		Scheme: issueURL.Scheme,
+		Host:   issueURL.Host,
+		Path:   t.path,
+	}
+}
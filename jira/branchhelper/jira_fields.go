@@ -0,0 +1,323 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// FieldMetadata describes a field Jira knows about
+type FieldMetadata struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Custom bool   `json:"custom"`
+}
+
+// GetFieldsClient allows us to get the fields Jira knows about
+type GetFieldsClient interface {
+	GetFields() ([]FieldMetadata, error)
+}
+
+// GetFieldsContextClient allows us to get the fields Jira knows about, giving
+// up when the context is done
+type GetFieldsContextClient interface {
+	GetFieldsContext(ctx context.Context) ([]FieldMetadata, error)
+}
+
+// FieldsClient gets field metadata from the Jira fields endpoint
+type FieldsClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// GetFields lists every system and custom field
+func (c FieldsClient) GetFields() ([]FieldMetadata, error) {
+	return c.GetFieldsContext(context.Background())
+}
+
+// GetFieldsContext lists every system and custom field, giving up when the
+// context is done
+func (c FieldsClient) GetFieldsContext(
+	ctx context.Context,
+) ([]FieldMetadata, error) {
+	req, err := c.Client.NewRequest(
+		"GET",
+		restAPIPath(c.APIVersion)+"field",
+		nil,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build fields request")
+	}
+
+	fields := []FieldMetadata{}
+	resp, err := c.Client.Do(req.WithContext(ctx), &fields)
+
+	if err != nil {
+		return nil, newRequestError(err, resp)
+	}
+
+	return fields, nil
+}
+
+// CachedFieldsClient only asks Jira for the fields until it gets an answer,
+// as they rarely change
+type CachedFieldsClient struct {
+	Client GetFieldsClient
+
+	mutex  sync.Mutex
+	fields []FieldMetadata
+}
+
+// GetFields lists every system and custom field, from the cache if possible
+func (c *CachedFieldsClient) GetFields() ([]FieldMetadata, error) {
+	return c.GetFieldsContext(context.Background())
+}
+
+// GetFieldsContext lists every system and custom field, from the cache if
+// possible, giving up when the context is done
+func (c *CachedFieldsClient) GetFieldsContext(
+	ctx context.Context,
+) ([]FieldMetadata, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.fields != nil {
+		return c.fields, nil
+	}
+
+	fields, err := getFields(ctx, c.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	c.fields = fields
+
+	return fields, nil
+}
+
+// getFields get the fields from a client, with the context if it supports one
+func getFields(
+	ctx context.Context,
+	client GetFieldsClient,
+) ([]FieldMetadata, error) {
+	if contextClient, ok := client.(GetFieldsContextClient); ok {
+		return contextClient.GetFieldsContext(ctx)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return client.GetFields()
+}
+
+// findField find a field by its display name, ignoring case, or its ID
+func findField(fields []FieldMetadata, name string) (FieldMetadata, bool) {
+	for _, field := range fields {
+		if field.ID == name || strings.EqualFold(field.Name, name) {
+			return field, true
+		}
+	}
+
+	return FieldMetadata{}, false
+}
+
+// issueFields the field metadata for an issue, from Jira if there is a client,
+// otherwise from the names the issue was expanded with
+func (helper *Jira) issueFields(
+	ctx context.Context,
+	issue *jira.Issue,
+) ([]FieldMetadata, error) {
+	if helper.Fields != nil {
+		fields, err := getFields(ctx, helper.Fields)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get fields from jira")
+		}
+
+		return fields, nil
+	}
+
+	if issue == nil || len(issue.Names) == 0 {
+		return nil, errors.New(
+			"no client to look up fields with, and issue has no names",
+		)
+	}
+
+	fields := []FieldMetadata{}
+
+	for id, name := range issue.Names {
+		fields = append(fields, FieldMetadata{
+			ID:     id,
+			Name:   name,
+			Custom: strings.HasPrefix(id, "customfield_"),
+		})
+	}
+
+	return fields, nil
+}
+
+// fieldValue the value of a field by its display name. If there's no way to
+// find out about the fields, the field is treated as unknown
+func (helper *Jira) fieldValue(
+	ctx context.Context,
+	issue *jira.Issue,
+	name string,
+) (interface{}, bool, error) {
+	if helper.Fields == nil && (issue == nil || len(issue.Names) == 0) {
+		return nil, false, nil
+	}
+
+	fields, err := helper.issueFields(ctx, issue)
+	if err != nil {
+		return nil, false, err
+	}
+
+	field, ok := findField(fields, name)
+	if !ok {
+		return nil, false, nil
+	}
+
+	values, err := issueFieldValues(issue)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return values[field.ID], true, nil
+}
+
+// fieldFunction builds the Field template function for an issue, which looks
+// up the value of a field by its display name
+func (helper *Jira) fieldFunction(
+	ctx context.Context,
+	issue *jira.Issue,
+) func(string) (string, error) {
+	return func(name string) (string, error) {
+		if helper.Fields == nil && (issue == nil || len(issue.Names) == 0) {
+			return "", errors.New(
+				"no client to look up fields with, and issue has no names",
+			)
+		}
+
+		value, ok, err := helper.fieldValue(ctx, issue, name)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "", errors.Errorf("jira has no field named %q", name)
+		}
+
+		return fieldValueString(value), nil
+	}
+}
+
+// issueFieldValues the fields of an issue, keyed by their ID, with custom
+// fields alongside the system ones
+func issueFieldValues(issue *jira.Issue) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	if issue == nil || issue.Fields == nil {
+		return values, nil
+	}
+
+	rawFields, err := json.Marshal(issue.Fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode issue fields")
+	}
+
+	if err := json.Unmarshal(rawFields, &values); err != nil {
+		return nil, errors.Wrap(err, "failed to decode issue fields")
+	}
+
+	return values, nil
+}
+
+// fieldValueString turns the different shapes of field value into something
+// printable. Options use their value, users their display name, rich text its
+// plain text, and lists are separated by commas
+func fieldValueString(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if doc, ok := ParseADF(typed); ok {
+			return doc.PlainText()
+		}
+
+		return typed
+	case float64:
+		return strconv.FormatFloat(typed, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(typed)
+	case []interface{}:
+		items := []string{}
+
+		for _, item := range typed {
+			if itemString := fieldValueString(item); itemString != "" {
+				items = append(items, itemString)
+			}
+		}
+
+		return strings.Join(items, ", ")
+	case map[string]interface{}:
+		if doc, ok := adfFromMap(typed); ok {
+			return doc.PlainText()
+		}
+
+		for _, key := range []string{"value", "displayName", "name", "key"} {
+			if nested, ok := typed[key]; ok {
+				return fieldValueString(nested)
+			}
+		}
+
+		return mapString(typed)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+// mapString prints a map we don't recognise the shape of in a stable order
+func mapString(value map[string]interface{}) string {
+	keys := []string{}
+
+	for key := range value {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	pairs := []string{}
+
+	for _, key := range keys {
+		pairs = append(
+			pairs,
+			key+"="+fieldValueString(value[key]),
+		)
+	}
+
+	return strings.Join(pairs, ", ")
+}
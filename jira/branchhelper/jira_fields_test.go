@@ -0,0 +1,208 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testFieldsJSON = `[
+	{"id": "summary", "name": "Summary", "custom": false},
+	{"id": "customfield_10011", "name": "Epic Name", "custom": true},
+	{"id": "customfield_10020", "name": "Team", "custom": true},
+	{"id": "customfield_10030", "name": "Components Owners", "custom": true},
+	{"id": "customfield_10040", "name": "Reviewer", "custom": true},
+	{"id": "customfield_10050", "name": "Story Points", "custom": true},
+	{"id": "customfield_10060", "name": "Empty", "custom": true}
+]`
+
+const testIssueJSON = `{
+	"key": "TST-123",
+	"fields": {
+		"summary": "Implement the login page",
+		"customfield_10011": "Login",
+		"customfield_10020": {"id": "1", "value": "Platform"},
+		"customfield_10030": [{"value": "Web"}, {"value": "API"}],
+		"customfield_10040": {"name": "jbloggs", "displayName": "Joe Bloggs"},
+		"customfield_10050": 3.5,
+		"customfield_10060": null
+	}
+}`
+
+var _ = Describe("FieldsClient", func() {
+	It("Gets the fields from Jira", func() {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.URL.Path).To(Equal("/rest/api/2/field"))
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(testFieldsJSON))
+			},
+		))
+		defer server.Close()
+		client, err := jira.NewClient(nil, server.URL+"/")
+		Expect(err).To(BeNil())
+
+		actual, err := FieldsClient{Client: client}.GetFields()
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(HaveLen(7))
+		Expect(actual[1]).To(Equal(FieldMetadata{
+			ID:     "customfield_10011",
+			Name:   "Epic Name",
+			Custom: true,
+		}))
+	})
+	It("Reports failures", func() {
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+		))
+		defer server.Close()
+		client, err := jira.NewClient(nil, server.URL+"/")
+		Expect(err).To(BeNil())
+
+		actual, err := FieldsClient{Client: client}.GetFields()
+
+		Expect(actual).To(BeNil())
+		Expect(err).ToNot(BeNil())
+	})
+})
+
+var _ = Describe("CachedFieldsClient", func() {
+	It("Only asks once", func() {
+		fields := &testGetFields{fields: testFields()}
+		subject := &CachedFieldsClient{Client: fields}
+
+		_, _ = subject.GetFields()
+		actual, err := subject.GetFields()
+
+		Expect(actual).To(HaveLen(7))
+		Expect(err).To(BeNil())
+		Expect(fields.calls).To(Equal(1))
+	})
+	It("Asks again after failures", func() {
+		fields := &testGetFields{err: errors.New("broken")}
+		subject := &CachedFieldsClient{Client: fields}
+
+		_, _ = subject.GetFields()
+		actual, err := subject.GetFields()
+
+		Expect(actual).To(BeNil())
+		Expect(err).ToNot(BeNil())
+		Expect(fields.calls).To(Equal(2))
+	})
+})
+
+var _ = Describe("Field template function", func() {
+	shapes := []struct {
+		name     string
+		templ    string
+		expected string
+	}{
+		{"text", `{{Field "Epic Name"}}`, "Login"},
+		{"option", `{{Field "Team" | KebabCase}}`, "platform"},
+		{"options", `{{Field "Components Owners"}}`, "Web, API"},
+		{"user", `{{Field "Reviewer"}}`, "Joe Bloggs"},
+		{"number", `{{Field "Story Points"}}`, "3.5"},
+		{"null", `{{Field "Empty" | Default "none"}}`, "none"},
+		{"system field", `{{Field "Summary" | Slug}}`, "implement-the-login-page"},
+		{"ignoring case", `{{Field "epic name"}}`, "Login"},
+		{"by id", `{{Field "customfield_10011"}}`, "Login"},
+	}
+
+	for _, shape := range shapes {
+		shape := shape
+
+		It("Formats "+shape.name+" fields", func() {
+			actual, err := fieldsJira().FormatIssue("TST-123", shape.templ)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(shape.expected))
+		})
+	}
+	It("Errors on unknown fields", func() {
+		actual, err := fieldsJira().FormatIssue("TST-123", `{{Field "Nope"}}`)
+
+		Expect(actual).To(Equal(""))
+		Expect(err).ToNot(BeNil())
+	})
+	It("Errors when fields can't be listed", func() {
+		subject := fieldsJira()
+		subject.Fields = &testGetFields{err: errors.New("broken")}
+
+		actual, err := subject.FormatIssue("TST-123", `{{Field "Team"}}`)
+
+		Expect(actual).To(Equal(""))
+		Expect(err).ToNot(BeNil())
+	})
+	It("Uses the issue names without a fields client", func() {
+		subject := fieldsJira()
+		subject.Fields = nil
+		issue := testIssue()
+		issue.Names = map[string]string{"customfield_10020": "Team"}
+		subject.Client = testGetIssue{issue: issue}
+
+		actual, err := subject.FormatIssue("TST-123", `{{Field "Team"}}`)
+
+		Expect(actual).To(Equal("Platform"))
+		Expect(err).To(BeNil())
+	})
+})
+
+func fieldsJira() *Jira {
+	return &Jira{
+		Client: testGetIssue{issue: testIssue()},
+		Fields: &testGetFields{fields: testFields()},
+	}
+}
+
+func testIssue() *jira.Issue {
+	issue := &jira.Issue{}
+	Expect(json.Unmarshal([]byte(testIssueJSON), issue)).To(Succeed())
+
+	return issue
+}
+
+func testFields() []FieldMetadata {
+	fields := []FieldMetadata{}
+	Expect(json.Unmarshal([]byte(testFieldsJSON), &fields)).To(Succeed())
+
+	return fields
+}
+
+type testGetFields struct {
+	fields []FieldMetadata
+	err    error
+	calls  int
+}
+
+func (t *testGetFields) GetFields() ([]FieldMetadata, error) {
+	t.calls++
+
+	return t.fields, t.err
+}
@@ -0,0 +1,296 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPClientOptions are how patient to be with Jira, and how to trust it
+type HTTPClientOptions struct {
+	// ConnectTimeout is how long to wait for a connection to open
+	ConnectTimeout time.Duration
+	// Timeout is how long a request can take in total, including retries
+	Timeout time.Duration
+	// MaxRetries is how many times to retry when Jira is busy or failing
+	MaxRetries int
+	// MinWait is how long to wait before the first retry, doubling each time
+	MinWait time.Duration
+	// MaxWait is the longest to wait between retries, even if Jira asks for
+	// longer
+	MaxWait time.Duration
+	// CAFile is a PEM file of certificate authorities to trust as well as the
+	// system ones
+	CAFile string
+	// InsecureSkipVerify turns off checking Jira's certificate
+	InsecureSkipVerify bool
+	// ClientCertFile is a PEM certificate to identify ourselves with, for
+	// proxies that require client certificates
+	ClientCertFile string
+	// ClientKeyFile is the PEM private key of the client certificate, if it
+	// isn't in the certificate file
+	ClientKeyFile string
+	// ClientKeyPassphrase is asked for the passphrase if the private key is
+	// encrypted
+	ClientKeyPassphrase func() ([]byte, error)
+	// Metrics times each attempt at a request, if set
+	Metrics *Metrics
+}
+
+// DefaultHTTPClientOptions are patient enough for a slow Jira, without hanging
+// forever on a dead connection
+func DefaultHTTPClientOptions() HTTPClientOptions {
+	return HTTPClientOptions{
+		ConnectTimeout: 10 * time.Second,
+		Timeout:        30 * time.Second,
+		MaxRetries:     3,
+		MinWait:        500 * time.Millisecond,
+		MaxWait:        10 * time.Second,
+	}
+}
+
+// NewHTTPClient builds a client for talking to Jira with timeouts, retries,
+// proxies from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables,
+// and any extra certificate authorities
+func NewHTTPClient(options HTTPClientOptions) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   options.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	var base http.RoundTripper = transport
+	if options.Metrics != nil {
+		base = &InstrumentedTransport{Transport: transport, Metrics: options.Metrics}
+	}
+
+	return &http.Client{
+		Timeout: options.Timeout,
+		Transport: &RetryTransport{
+			Transport:  base,
+			MaxRetries: options.MaxRetries,
+			MinWait:    options.MinWait,
+			MaxWait:    options.MaxWait,
+		},
+	}, nil
+}
+
+func newTLSConfig(options HTTPClientOptions) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+
+	if options.ClientCertFile != "" {
+		certificate, err := loadClientCertificate(options)
+		if err != nil {
+			return nil, err
+		}
+
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	if options.CAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(options.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read certificate authorities")
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.Errorf(
+			"no certificates found in %s",
+			options.CAFile,
+		)
+	}
+
+	config.RootCAs = pool
+
+	return config, nil
+}
+
+// RetryTransport retries requests when Jira is rate limiting or failing,
+// waiting longer each time, or as long as Jira asks in Retry-After. Requests
+// Jira may have acted on are only retried if repeating them is safe
+type RetryTransport struct {
+	Transport  http.RoundTripper
+	MaxRetries int
+	MinWait    time.Duration
+	MaxWait    time.Duration
+	// Sleep waits between attempts, if not set the wait is cut short when the
+	// request is cancelled
+	Sleep func(time.Duration)
+}
+
+// RoundTrip makes the request, retrying it if needed
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := transport.RoundTrip(req)
+		if err != nil || attempt >= t.MaxRetries || !retryable(req, resp) {
+			return resp, err
+		}
+
+		retryReq, err := rewind(req)
+		if err != nil {
+			return resp, nil
+		}
+
+		wait := t.wait(attempt, resp)
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		if err := t.sleep(req, wait); err != nil {
+			return nil, err
+		}
+
+		req = retryReq
+	}
+}
+
+// retryable if the response says Jira is busy or failing. Too many requests
+// and unavailable mean the request wasn't acted on, other failures might have
+// been, so are only retried for idempotent methods
+func retryable(req *http.Request, resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+
+	if resp.StatusCode < 500 || resp.StatusCode == http.StatusNotImplemented {
+		return false
+	}
+
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+
+	return false
+}
+
+// rewind get a copy of the request that can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+
+	if req.GetBody == nil {
+		return nil, errors.New("request body can not be sent again")
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	retryReq := *req
+	retryReq.Body = body
+
+	return &retryReq, nil
+}
+
+// wait how long to wait before the next attempt, Jira's Retry-After if it
+// gives one, otherwise doubling each attempt
+func (t *RetryTransport) wait(attempt int, resp *http.Response) time.Duration {
+	wait := t.MinWait << uint(attempt)
+
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+		wait = retryAfter
+	}
+
+	if t.MaxWait > 0 && (wait > t.MaxWait || wait < 0) {
+		wait = t.MaxWait
+	}
+
+	return wait
+}
+
+// parseRetryAfter reads a Retry-After header, either as seconds or a date
+func parseRetryAfter(header string) (time.Duration, bool) {
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, true
+	}
+
+	return 0, false
+}
+
+func (t *RetryTransport) sleep(req *http.Request, wait time.Duration) error {
+	if t.Sleep != nil {
+		t.Sleep(wait)
+
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Cancel:
+		return errors.New("request cancelled")
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,244 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryTransport", func() {
+	var statuses []int
+	var retryAfter string
+	var bodies []string
+	var waits []time.Duration
+	var server *httptest.Server
+	var subject *http.Client
+
+	BeforeEach(func() {
+		statuses = []int{}
+		retryAfter = ""
+		bodies = []string{}
+		waits = []time.Duration{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+
+			status := http.StatusOK
+			if len(statuses) > 0 {
+				status, statuses = statuses[0], statuses[1:]
+			}
+
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+
+			w.WriteHeader(status)
+		}))
+		subject = &http.Client{Transport: &RetryTransport{
+			MaxRetries: 2,
+			MinWait:    time.Second,
+			MaxWait:    5 * time.Second,
+			Sleep: func(wait time.Duration) {
+				waits = append(waits, wait)
+			},
+		}}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Retries failures, waiting longer each time", func() {
+		statuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable}
+
+		resp, err := subject.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(waits).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+	})
+	It("Gives up after the last retry", func() {
+		statuses = []int{500, 500, 500, 500}
+
+		resp, err := subject.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(bodies).To(HaveLen(3))
+	})
+	It("Waits as long as Jira asks", func() {
+		statuses = []int{http.StatusTooManyRequests}
+		retryAfter = "3"
+
+		_, err := subject.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(waits).To(Equal([]time.Duration{3 * time.Second}))
+	})
+	It("Waits no longer than the maximum", func() {
+		statuses = []int{http.StatusTooManyRequests}
+		retryAfter = "120"
+
+		_, err := subject.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(waits).To(Equal([]time.Duration{5 * time.Second}))
+	})
+	It("Does not retry other errors", func() {
+		statuses = []int{http.StatusNotFound}
+
+		resp, err := subject.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(bodies).To(HaveLen(1))
+	})
+	It("Sends the body again when rate limited", func() {
+		statuses = []int{http.StatusTooManyRequests}
+
+		resp, err := subject.Post(server.URL, "text/plain", strings.NewReader("hello"))
+
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(bodies).To(Equal([]string{"hello", "hello"}))
+	})
+	It("Does not repeat requests Jira may have acted on", func() {
+		statuses = []int{http.StatusInternalServerError}
+
+		resp, err := subject.Post(server.URL, "text/plain", strings.NewReader("hello"))
+
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusInternalServerError))
+		Expect(bodies).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("NewHTTPClient", func() {
+	var server *httptest.Server
+	var dir string
+
+	BeforeEach(func() {
+		server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/slow" {
+				time.Sleep(200 * time.Millisecond)
+			}
+			if r.URL.Path == "/rest/api/2/myself" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+
+		var err error
+		dir, err = ioutil.TempDir("", "jira-branch-helper")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		server.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	caFile := func() string {
+		path := filepath.Join(dir, "ca.pem")
+		certificate := pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.Certificate().Raw,
+		})
+		Expect(ioutil.WriteFile(path, certificate, 0600)).To(Succeed())
+
+		return path
+	}
+
+	It("Does not trust unknown certificate authorities", func() {
+		client, err := NewHTTPClient(DefaultHTTPClientOptions())
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL)
+		Expect(err).ToNot(BeNil())
+	})
+	It("Trusts certificate authorities from a file", func() {
+		options := DefaultHTTPClientOptions()
+		options.CAFile = caFile()
+		client, err := NewHTTPClient(options)
+		Expect(err).To(BeNil())
+
+		resp, err := client.Get(server.URL)
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	})
+	It("Can skip verifying certificates", func() {
+		options := DefaultHTTPClientOptions()
+		options.InsecureSkipVerify = true
+		client, err := NewHTTPClient(options)
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL)
+		Expect(err).To(BeNil())
+	})
+	It("Fails if the certificate authority file has no certificates", func() {
+		options := DefaultHTTPClientOptions()
+		options.CAFile = filepath.Join(dir, "empty.pem")
+		Expect(ioutil.WriteFile(options.CAFile, []byte("nope"), 0600)).To(Succeed())
+
+		_, err := NewHTTPClient(options)
+		Expect(err).ToNot(BeNil())
+
+		options.CAFile = filepath.Join(dir, "missing.pem")
+		_, err = NewHTTPClient(options)
+		Expect(err).ToNot(BeNil())
+	})
+	It("Gives up on slow requests", func() {
+		options := DefaultHTTPClientOptions()
+		options.InsecureSkipVerify = true
+		options.Timeout = 50 * time.Millisecond
+		client, err := NewHTTPClient(options)
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL + "/slow")
+		Expect(err).ToNot(BeNil())
+	})
+	It("Times each attempt at a request", func() {
+		options := DefaultHTTPClientOptions()
+		options.InsecureSkipVerify = true
+		options.MaxRetries = 2
+		options.MinWait = time.Millisecond
+		options.MaxWait = time.Millisecond
+		options.Metrics = NewMetrics()
+		client, err := NewHTTPClient(options)
+		Expect(err).To(BeNil())
+
+		resp, err := client.Get(server.URL + "/rest/api/2/myself")
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+		Expect(metricsText(options.Metrics)).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="myself",status="503"} 3`,
+		))
+	})
+})
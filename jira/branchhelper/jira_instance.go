@@ -0,0 +1,95 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Instance is a named Jira deployment, the projects that live on it and the
+// details needed to talk to it
+type Instance struct {
+	Name              string   `json:"name"`
+	Endpoint          string   `json:"endpoint"`
+	Projects          []string `json:"projects"`
+	Template          string   `json:"template"`
+	Transition        string   `json:"transition"`
+	APIVersion        string   `json:"apiVersion"`
+	BasicAuthUsername string   `json:"basicAuthUsername"`
+	BasicAuthPassword string   `json:"basicAuthPassword"`
+	Username          string   `json:"username"`
+	Password          string   `json:"password"`
+}
+
+// Instances is a list of Jira deployments that issues can be routed between
+type Instances []Instance
+
+func (instance Instance) ownsProject(project string) bool {
+	for i := range instance.Projects {
+		if strings.EqualFold(instance.Projects[i], project) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (instance Instance) ownsURL(issueURL *url.URL) bool {
+	endpointURL, err := url.Parse(instance.Endpoint)
+	if err != nil || endpointURL.Host == "" {
+		return false
+	}
+
+	if !strings.EqualFold(endpointURL.Host, issueURL.Host) {
+		return false
+	}
+
+	// Whole path segments only, so "/jira" doesn't own "/jira2"
+	path := strings.TrimSuffix(endpointURL.Path, "/")
+
+	return issueURL.Path == path || strings.HasPrefix(issueURL.Path, path+"/")
+}
+
+// Select the instance that owns an issue. Issue URLs are matched on the host
+// of the endpoint, bare issue keys on their project prefix. Returns nil when
+// no instance matches
+func (instances Instances) Select(rawIssue string) *Instance {
+	issueURL, err := url.Parse(rawIssue)
+
+	if err == nil && issueURL.Host != "" {
+		for i := range instances {
+			if instances[i].ownsURL(issueURL) {
+				return &instances[i]
+			}
+		}
+
+		return nil
+	}
+
+	project := strings.SplitN(rawIssue, "-", 2)[0]
+
+	for i := range instances {
+		if instances[i].ownsProject(project) {
+			return &instances[i]
+		}
+	}
+
+	return nil
+}
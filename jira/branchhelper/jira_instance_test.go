@@ -0,0 +1,80 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instances", func() {
+	instances := Instances{
+		{
+			Name:     "cloud",
+			Endpoint: "https://example.atlassian.net/",
+			Projects: []string{"TST", "OPS"},
+		},
+		{
+			Name:     "legacy",
+			Endpoint: "https://example.com/jira/",
+			Projects: []string{"OLD"},
+		},
+	}
+
+	Context("Failure", func() {
+		It("Returns nil for unknown projects", func() {
+			Expect(instances.Select("NOPE-123")).To(BeNil())
+		})
+		It("Returns nil for unknown hosts", func() {
+			Expect(
+				instances.Select("https://other.example.com/browse/TST-123"),
+			).To(BeNil())
+		})
+		It("Returns nil when the path is outside the endpoint", func() {
+			Expect(
+				instances.Select("https://example.com/browse/OLD-123"),
+			).To(BeNil())
+		})
+		It("Returns nil when the path only starts like the endpoint", func() {
+			Expect(
+				instances.Select("https://example.com/jira2/browse/OLD-123"),
+			).To(BeNil())
+		})
+		It("Returns nil when there are no instances", func() {
+			Expect(Instances{}.Select("TST-123")).To(BeNil())
+		})
+	})
+	Context("Success", func() {
+		It("Routes keys by project prefix", func() {
+			Expect(instances.Select("OPS-1").Name).To(Equal("cloud"))
+			Expect(instances.Select("OLD-1").Name).To(Equal("legacy"))
+		})
+		It("Ignores the case of the project", func() {
+			Expect(instances.Select("tst-1").Name).To(Equal("cloud"))
+		})
+		It("Routes urls by host", func() {
+			actual := instances.Select(
+				"https://example.com/jira/browse/TST-123",
+			)
+
+			Expect(actual.Name).To(Equal("legacy"))
+		})
+	})
+})
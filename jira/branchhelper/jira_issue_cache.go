@@ -0,0 +1,143 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// DefaultIssueCacheTTL is how long issues are remembered for by default
+const DefaultIssueCacheTTL = 5 * time.Minute
+
+// CachedIssueClient remembers the issues it gets from Jira for a while, for
+// long running processes that are asked about the same issues again and
+// again. Issues are remembered for each query, as different templates fetch
+// different fields. Only issues are remembered, so failures are asked about
+// again. The issues it returns are shared, and should not be changed
+type CachedIssueClient struct {
+	Client GetIssueClient
+	// TTL is how long an issue is remembered, DefaultIssueCacheTTL if zero
+	TTL time.Duration
+	// Now is the current time, time.Now if nil
+	Now func() time.Time
+	// Metrics counts how often issues are found in the cache, if not nil
+	Metrics *Metrics
+
+	mutex  sync.Mutex
+	issues map[string]cachedIssue
+}
+
+type cachedIssue struct {
+	issue   *jira.Issue
+	expires time.Time
+}
+
+// Get an issue, from the cache if possible
+func (c *CachedIssueClient) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	return c.GetContext(context.Background(), issueID, options)
+}
+
+// GetContext get an issue, from the cache if possible, giving up when the
+// context is done. There is no response for issues from the cache
+func (c *CachedIssueClient) GetContext(
+	ctx context.Context,
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	key := issueID + issueQuery(options)
+
+	if issue := c.lookup(key); issue != nil {
+		c.Metrics.ObserveCache(true)
+
+		return issue, nil, nil
+	}
+
+	c.Metrics.ObserveCache(false)
+	issue, resp, err := getIssueContext(ctx, c.Client, issueID, options)
+
+	if err == nil && issue != nil {
+		c.remember(key, issue)
+	}
+
+	return issue, resp, err
+}
+
+// Forget every issue, so they are asked for again
+func (c *CachedIssueClient) Forget() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.issues = nil
+}
+
+func (c *CachedIssueClient) lookup(key string) *jira.Issue {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cached, ok := c.issues[key]
+	if !ok || !c.now().Before(cached.expires) {
+		return nil
+	}
+
+	return cached.issue
+}
+
+// remember an issue, dropping any that have expired so the cache only holds
+// issues asked about recently
+func (c *CachedIssueClient) remember(key string, issue *jira.Issue) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+
+	if c.issues == nil {
+		c.issues = map[string]cachedIssue{}
+	}
+
+	for cachedKey, cached := range c.issues {
+		if !now.Before(cached.expires) {
+			delete(c.issues, cachedKey)
+		}
+	}
+
+	c.issues[key] = cachedIssue{issue: issue, expires: now.Add(c.ttl())}
+}
+
+func (c *CachedIssueClient) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return DefaultIssueCacheTTL
+	}
+
+	return c.TTL
+}
+
+func (c *CachedIssueClient) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+
+	return c.Now()
+}
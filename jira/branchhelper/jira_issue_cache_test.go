@@ -0,0 +1,119 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingGetIssue answers with an issue, or an error, and counts how often
+// it is asked
+type countingGetIssue struct {
+	calls *int
+	err   error
+}
+
+func (c countingGetIssue) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	*c.calls++
+
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+
+	return &jira.Issue{Key: issueID}, nil, nil
+}
+
+var _ = Describe("CachedIssueClient", func() {
+	var calls int
+	var now time.Time
+	var subject *CachedIssueClient
+
+	BeforeEach(func() {
+		calls = 0
+		now = time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+		subject = &CachedIssueClient{
+			Client: countingGetIssue{calls: &calls},
+			TTL:    time.Minute,
+			Now:    func() time.Time { return now },
+		}
+	})
+
+	It("Only asks Jira once", func() {
+		first, _, err := subject.Get("TST-123", nil)
+		Expect(err).To(BeNil())
+
+		second, _, err := subject.Get("TST-123", nil)
+		Expect(err).To(BeNil())
+
+		Expect(second).To(Equal(first))
+		Expect(calls).To(Equal(1))
+	})
+	It("Asks again for each issue", func() {
+		_, _, _ = subject.Get("TST-123", nil)
+		actual, _, err := subject.Get("TST-124", nil)
+
+		Expect(err).To(BeNil())
+		Expect(actual.Key).To(Equal("TST-124"))
+		Expect(calls).To(Equal(2))
+	})
+	It("Asks again for each query", func() {
+		_, _, _ = subject.Get("TST-123", &jira.GetQueryOptions{Fields: "summary"})
+		_, _, _ = subject.Get("TST-123", &jira.GetQueryOptions{Fields: "labels"})
+		_, _, _ = subject.Get("TST-123", &jira.GetQueryOptions{Fields: "summary"})
+
+		Expect(calls).To(Equal(2))
+	})
+	It("Asks again once the issue expires", func() {
+		_, _, _ = subject.Get("TST-123", nil)
+		now = now.Add(time.Minute)
+		_, _, _ = subject.Get("TST-123", nil)
+
+		Expect(calls).To(Equal(2))
+	})
+	It("Asks again once forgotten", func() {
+		_, _, _ = subject.Get("TST-123", nil)
+		subject.Forget()
+		_, _, _ = subject.Get("TST-123", nil)
+
+		Expect(calls).To(Equal(2))
+	})
+	It("Asks again after failures", func() {
+		subject.Client = countingGetIssue{
+			calls: &calls,
+			err:   errors.New("unavailable"),
+		}
+
+		_, _, err := subject.Get("TST-123", nil)
+		Expect(err).ToNot(BeNil())
+
+		_, _, err = subject.Get("TST-123", nil)
+		Expect(err).ToNot(BeNil())
+
+		Expect(calls).To(Equal(2))
+	})
+})
@@ -0,0 +1,151 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// IssueClient gets issues from a chosen version of the Jira API. Unlike the
+// go-jira issue service, requests can be cancelled with a context. Rich text in
+// version 3 is an ADF document rather than a string, so each document is kept
+// as its JSON, which the PlainText template function turns back into text
+type IssueClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// Get an issue, as the go-jira issue service does
+func (c IssueClient) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	return c.GetContext(context.Background(), issueID, options)
+}
+
+// GetContext get an issue, giving up when the context is done
+func (c IssueClient) GetContext(
+	ctx context.Context,
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	req, err := c.Client.NewRequest(
+		"GET",
+		issuePath(c.APIVersion, issueID, issueQuery(options)),
+		nil,
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to build issue request")
+	}
+
+	raw := json.RawMessage{}
+	resp, err := c.Client.Do(req.WithContext(ctx), &raw)
+
+	if err != nil {
+		return nil, resp, err
+	}
+
+	issue, err := decodeIssue(raw)
+
+	return issue, resp, err
+}
+
+func issueQuery(options *jira.GetQueryOptions) string {
+	if options == nil {
+		return ""
+	}
+
+	query := url.Values{}
+
+	for key, value := range map[string]string{
+		"fields":     options.Fields,
+		"expand":     options.Expand,
+		"properties": options.Properties,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	if options.FieldsByKeys {
+		query.Set("fieldsByKeys", "true")
+	}
+
+	if options.UpdateHistory {
+		query.Set("updateHistory", "true")
+	}
+
+	if len(query) == 0 {
+		return ""
+	}
+
+	return "?" + query.Encode()
+}
+
+// decodeIssue reads an issue, keeping any ADF documents as JSON strings so
+// they fit the string fields of jira.Issue
+func decodeIssue(raw []byte) (*jira.Issue, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, errors.Wrap(err, "failed to read issue")
+	}
+
+	flattened, err := json.Marshal(flattenADF(value))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read issue")
+	}
+
+	issue := &jira.Issue{}
+
+	if err := json.Unmarshal(flattened, issue); err != nil {
+		return nil, errors.Wrap(err, "failed to read issue")
+	}
+
+	return issue, nil
+}
+
+func flattenADF(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if typed["type"] == "doc" {
+			if doc, err := json.Marshal(typed); err == nil {
+				return string(doc)
+			}
+		}
+
+		for key, nested := range typed {
+			typed[key] = flattenADF(nested)
+		}
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = flattenADF(nested)
+		}
+	}
+
+	return value
+}
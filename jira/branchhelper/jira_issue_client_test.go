@@ -0,0 +1,126 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"net/http"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IssueClient", func() {
+	var requests []*http.Request
+	var client *jira.Client
+	var subject IssueClient
+	var stop func()
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		var closeServer func()
+		client, closeServer = testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+			w.Header().Set("Content-Type", "application/json")
+
+			switch r.URL.Path {
+			case "/rest/api/3/issue/TST-123":
+				_, _ = w.Write([]byte(`{
+					"key": "TST-123",
+					"fields": {
+						"summary": "Login page",
+						"description": ` + testADFDescription + `,
+						"customfield_10040": {"type": "doc", "version": 1, "content": [
+							{"type": "paragraph", "content": [{"type": "text", "text": "Notes"}]}
+						]},
+						"customfield_10041": 12345678901234567890,
+						"comment": {"comments": [{"id": "1", "body": {
+							"type": "doc", "version": 1, "content": [
+								{"type": "paragraph", "content": [{"type": "text", "text": "Hi"}]}
+							]
+						}}]}
+					}
+				}`))
+			case "/rest/api/3/field":
+				_, _ = w.Write([]byte(`[
+					{"id": "summary", "name": "Summary"},
+					{"id": "customfield_10040", "name": "Notes", "custom": true}
+				]`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		subject = IssueClient{Client: client, APIVersion: APIVersion3}
+		stop = closeServer
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("Gets the issue from the chosen version of the API", func() {
+		issue, _, err := subject.Get(
+			"TST-123",
+			&jira.GetQueryOptions{Fields: "summary,description", Expand: "names"},
+		)
+
+		Expect(err).To(BeNil())
+		Expect(issue.Key).To(Equal("TST-123"))
+		Expect(issue.Fields.Summary).To(Equal("Login page"))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.Query().Get("fields")).To(Equal("summary,description"))
+		Expect(requests[0].URL.Query().Get("expand")).To(Equal("names"))
+	})
+	It("Keeps rich text as documents", func() {
+		issue, _, err := subject.Get("TST-123", nil)
+
+		Expect(err).To(BeNil())
+
+		description, ok := ParseADF(issue.Fields.Description)
+		Expect(ok).To(BeTrue())
+		Expect(description.PlainText()).To(HavePrefix("Login page\n"))
+
+		comment, ok := ParseADF(issue.Fields.Comments.Comments[0].Body)
+		Expect(ok).To(BeTrue())
+		Expect(comment.PlainText()).To(Equal("Hi"))
+	})
+	It("Fails on missing issues", func() {
+		_, _, err := subject.Get("TST-404", nil)
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Is used for templates", func() {
+		Expect(NewJiraWithAPIVersion(client, APIVersion2).Client).To(
+			Equal(IssueClient{Client: client, APIVersion: APIVersion2}),
+		)
+
+		helper := NewJiraWithAPIVersion(client, APIVersion3)
+		Expect(helper.Client).To(Equal(subject))
+
+		actual, err := helper.FormatIssue(
+			"TST-123",
+			"{{.Fields.Description | PlainText | FirstWords 5}} "+
+				"{{Field \"Notes\"}}",
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("Login page Ask @Billie about Notes"))
+	})
+})
@@ -31,6 +31,13 @@ type IssueStrategy interface {
 	GetIssue(rawIssue string) (string, error)
 }
 
+// ApplicableIssueStrategy is an IssueStrategy that can tell if it is able to
+// handle an issue URL
+type ApplicableIssueStrategy interface {
+	IssueStrategy
+	Applicable(issueURL *url.URL) bool
+}
+
 // IssueLiteralStrategy take what the user provided verboten
 type IssueLiteralStrategy struct {
 }
@@ -40,6 +47,11 @@ type IssueLiteralStrategy struct {
 type IssueURLStrategy struct {
 }
 
+// Applicable the URL strategy handles anything with a host
+func (c IssueURLStrategy) Applicable(issueURL *url.URL) bool {
+	return issueURL != nil && issueURL.Host != ""
+}
+
 // GetIssue extracts the issue number from the issue
 func (c IssueURLStrategy) GetIssue(rawIssue string) (string, error) {
 	issueURL, err := url.Parse(rawIssue)
@@ -59,13 +71,57 @@ func (c IssueLiteralStrategy) GetIssue(rawIssue string) (string, error) {
 	return rawIssue, nil
 }
 
-// MakeIssueStrategy make an issue strategy based on the URL provided
+// IssueStrategyPicker picks the first registered strategy that is applicable
+// to an issue URL, or the fallback if none are
+type IssueStrategyPicker struct {
+	Fallback   IssueStrategy
+	strategies []ApplicableIssueStrategy
+}
+
+// NewIssueStrategyPicker build a picker that tries the given strategies in
+// order
+func NewIssueStrategyPicker(
+	fallback IssueStrategy,
+	strategies ...ApplicableIssueStrategy,
+) *IssueStrategyPicker {
+	return &IssueStrategyPicker{Fallback: fallback, strategies: strategies}
+}
+
+// DefaultIssueStrategyPicker build a picker with the built in strategies
+func DefaultIssueStrategyPicker() *IssueStrategyPicker {
+	return NewIssueStrategyPicker(IssueLiteralStrategy{}, IssueURLStrategy{})
+}
+
+// Register add a strategy to try after the ones already registered
+func (p *IssueStrategyPicker) Register(strategy ApplicableIssueStrategy) {
+	p.strategies = append(p.strategies, strategy)
+}
+
+// RegisterFirst add a strategy to try before the ones already registered
+func (p *IssueStrategyPicker) RegisterFirst(strategy ApplicableIssueStrategy) {
+	p.strategies = append(
+		[]ApplicableIssueStrategy{strategy},
+		p.strategies...,
+	)
+}
+
+// Pick the strategy to use for the URL provided
+func (p *IssueStrategyPicker) Pick(issueURL *url.URL) IssueStrategy {
+	if issueURL != nil {
+		for i := range p.strategies {
+			if p.strategies[i].Applicable(issueURL) {
+				return p.strategies[i]
+			}
+		}
+	}
+
+	return p.Fallback
+}
+
+// MakeIssueStrategy make an issue strategy based on the URL provided, using
+// the built in strategies
 func MakeIssueStrategy(
 	issueURL *url.URL,
 ) IssueStrategy {
-	if issueURL != nil && issueURL.Host != "" {
-		return IssueURLStrategy{}
-	}
-
-	return IssueLiteralStrategy{}
+	return DefaultIssueStrategyPicker().Pick(issueURL)
 }
@@ -95,3 +95,46 @@ var _ = Describe("MakeIssueStrategy", func() {
 		})
 	})
 })
+
+var _ = Describe("IssueStrategyPicker", func() {
+	Context("fallback", func() {
+		It("No url", func() {
+			actual := DefaultIssueStrategyPicker().Pick(nil)
+
+			Expect(actual).To(BeAssignableToTypeOf(IssueLiteralStrategy{}))
+		})
+		It("Nothing applicable", func() {
+			url, _ := url.Parse("https://example.com/jira/TST-101")
+			actual := NewIssueStrategyPicker(IssueLiteralStrategy{}).Pick(url)
+
+			Expect(actual).To(BeAssignableToTypeOf(IssueLiteralStrategy{}))
+		})
+	})
+	Context("registered strategies", func() {
+		It("Are tried after the defaults", func() {
+			picker := DefaultIssueStrategyPicker()
+			picker.Register(testIssueStrategy{})
+			url, _ := url.Parse("https://example.com/jira/TST-101")
+
+			Expect(picker.Pick(url)).To(BeAssignableToTypeOf(IssueURLStrategy{}))
+		})
+		It("Can take priority", func() {
+			picker := DefaultIssueStrategyPicker()
+			picker.RegisterFirst(testIssueStrategy{})
+			url, _ := url.Parse("https://example.com/jira/TST-101")
+
+			Expect(picker.Pick(url)).To(BeAssignableToTypeOf(testIssueStrategy{}))
+		})
+	})
+})
+
+type testIssueStrategy struct {
+}
+
+func (t testIssueStrategy) Applicable(issueURL *url.URL) bool {
+	return issueURL.Host == "example.com"
+}
+
+func (t testIssueStrategy) GetIssue(rawIssue string) (string, error) {
+	return "TST-1", nil
+}
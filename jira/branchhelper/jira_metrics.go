@@ -0,0 +1,391 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The outcomes of building a branch name
+const (
+	RenderSuccess       = "success"
+	RenderNotFound      = "not_found"
+	RenderJiraError     = "jira_error"
+	RenderTemplateError = "template_error"
+	RenderBadRequest    = "bad_request"
+)
+
+// metricsPrefix starts the name of every metric
+const metricsPrefix = "jira_branch_helper_"
+
+// metricsBuckets are the upper bounds of the histogram buckets in seconds,
+// the same as the Prometheus client libraries use
+var metricsBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// Metrics counts what a long running process does, in the Prometheus text
+// format. It is safe for concurrent use, and a nil *Metrics records nothing,
+// so code can be instrumented whether or not metrics are wanted
+type Metrics struct {
+	mutex          sync.Mutex
+	renders        map[string]uint64
+	renderDuration map[string]*histogram
+	requests       map[string]*histogram
+	authFailures   uint64
+	cacheResults   map[string]uint64
+}
+
+// histogram counts observations into buckets
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, bound := range metricsBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+
+	h.sum += seconds
+	h.count++
+}
+
+// NewMetrics with nothing counted yet
+func NewMetrics() *Metrics {
+	return &Metrics{
+		renders:        map[string]uint64{},
+		renderDuration: map[string]*histogram{},
+		requests:       map[string]*histogram{},
+		cacheResults:   map[string]uint64{"hit": 0, "miss": 0},
+	}
+}
+
+// ObserveRender count a branch name being built, e.g. from the "api" or a
+// "webhook", how it turned out, and how long executing the template took
+func (m *Metrics) ObserveRender(
+	source string,
+	outcome string,
+	duration time.Duration,
+) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.renders[labels("source", source, "outcome", outcome)]++
+
+	if duration > 0 {
+		observe(m.renderDuration, labels("source", source), duration)
+	}
+}
+
+// ObserveRequest count a request to an endpoint of the Jira API, and how long
+// it took. The status is the HTTP status, or "error" if there wasn't one.
+// Requests Jira refused are counted as authentication failures
+func (m *Metrics) ObserveRequest(
+	endpoint string,
+	status string,
+	duration time.Duration,
+) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	observe(m.requests, labels("endpoint", endpoint, "status", status), duration)
+
+	if status == strconv.Itoa(http.StatusUnauthorized) ||
+		status == strconv.Itoa(http.StatusForbidden) {
+		m.authFailures++
+	}
+}
+
+// ObserveCache count an issue being found in the cache, or not
+func (m *Metrics) ObserveCache(hit bool) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if hit {
+		m.cacheResults["hit"]++
+	} else {
+		m.cacheResults["miss"]++
+	}
+}
+
+// ServeHTTP answer with the metrics, for Prometheus to scrape
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo write the metrics in the Prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writer := &metricsWriter{writer: w}
+
+	writer.counters(
+		"renders_total",
+		"Branch names built, by where they were asked for and outcome",
+		m.renders,
+	)
+	writer.histograms(
+		"render_duration_seconds",
+		"Time taken executing the template, once the issue has been fetched",
+		m.renderDuration,
+	)
+	writer.histograms(
+		"jira_request_duration_seconds",
+		"Time taken by each attempt at a request to Jira, by endpoint and status",
+		m.requests,
+	)
+	writer.counters(
+		"jira_auth_failures_total",
+		"Requests Jira refused as unauthorised or forbidden",
+		map[string]uint64{"": m.authFailures},
+	)
+	writer.counters(
+		"issue_cache_requests_total",
+		"Issues asked for from the cache, by whether they were found",
+		labelled("result", m.cacheResults),
+	)
+
+	return writer.written, writer.err
+}
+
+func observe(
+	histograms map[string]*histogram,
+	key string,
+	duration time.Duration,
+) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(metricsBuckets))}
+		histograms[key] = h
+	}
+
+	h.observe(duration.Seconds())
+}
+
+// labels format label pairs, e.g. `endpoint="issue",status="200"`
+func labels(pairs ...string) string {
+	formatted := []string{}
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		formatted = append(
+			formatted,
+			pairs[i]+`="`+escapeLabel(pairs[i+1])+`"`,
+		)
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+func labelled(name string, values map[string]uint64) map[string]uint64 {
+	result := map[string]uint64{}
+
+	for value, count := range values {
+		result[labels(name, value)] = count
+	}
+
+	return result
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func sortedKeys(values interface{}) []string {
+	keys := []string{}
+
+	switch typed := values.(type) {
+	case map[string]uint64:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	case map[string]*histogram:
+		for key := range typed {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// metricsWriter writes metrics, remembering the first error so each line
+// doesn't need checking
+type metricsWriter struct {
+	writer  io.Writer
+	written int64
+	err     error
+}
+
+func (w *metricsWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(w.writer, format, args...)
+	w.written += int64(n)
+	w.err = err
+}
+
+func (w *metricsWriter) header(name string, help string, kind string) {
+	w.printf("# HELP %s%s %s\n", metricsPrefix, name, help)
+	w.printf("# TYPE %s%s %s\n", metricsPrefix, name, kind)
+}
+
+func (w *metricsWriter) counters(
+	name string,
+	help string,
+	values map[string]uint64,
+) {
+	w.header(name, help, "counter")
+
+	for _, key := range sortedKeys(values) {
+		w.printf("%s%s%s %d\n", metricsPrefix, name, braces(key), values[key])
+	}
+}
+
+func (w *metricsWriter) histograms(
+	name string,
+	help string,
+	values map[string]*histogram,
+) {
+	w.header(name, help, "histogram")
+
+	for _, key := range sortedKeys(values) {
+		h := values[key]
+		prefix := key
+		if prefix != "" {
+			prefix += ","
+		}
+
+		for i, bound := range metricsBuckets {
+			w.printf(
+				"%s%s_bucket{%sle=\"%s\"} %d\n",
+				metricsPrefix,
+				name,
+				prefix,
+				strconv.FormatFloat(bound, 'g', -1, 64),
+				h.buckets[i],
+			)
+		}
+
+		w.printf("%s%s_bucket{%sle=\"+Inf\"} %d\n", metricsPrefix, name, prefix, h.count)
+		w.printf(
+			"%s%s_sum%s %s\n",
+			metricsPrefix,
+			name,
+			braces(key),
+			strconv.FormatFloat(h.sum, 'g', -1, 64),
+		)
+		w.printf("%s%s_count%s %d\n", metricsPrefix, name, braces(key), h.count)
+	}
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+
+	return "{" + labels + "}"
+}
+
+// endpointReg picks the endpoint out of the path of a request to Jira, e.g.
+// "issue" and "comment" from "/jira/rest/api/2/issue/TST-123/comment", so
+// requests can be counted without a label for each issue
+var endpointReg = regexp.MustCompile(
+	`/rest/[a-z]+/[0-9.]+/([^/]+)(?:/[^/]+/([^/]+))?`,
+)
+
+// InstrumentedTransport times every request made to Jira, counting them in
+// the metrics by the endpoint they are for. Put it inside a RetryTransport
+// to time each attempt, without the waits between them
+type InstrumentedTransport struct {
+	Transport http.RoundTripper
+	Metrics   *Metrics
+}
+
+// RoundTrip makes the request, timing it
+func (t *InstrumentedTransport) RoundTrip(
+	req *http.Request,
+) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	status := "error"
+
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	t.Metrics.ObserveRequest(requestEndpoint(req.URL), status, time.Since(start))
+
+	return resp, err
+}
+
+// requestEndpoint the endpoint of the Jira API a request is for, e.g. "issue",
+// "issue/comment", "search" or "myself", or "other" if it isn't for the API
+func requestEndpoint(target *url.URL) string {
+	match := endpointReg.FindStringSubmatch(target.Path)
+
+	switch {
+	case match == nil:
+		return "other"
+	case match[2] != "":
+		return match[1] + "/" + match[2]
+	}
+
+	return match[1]
+}
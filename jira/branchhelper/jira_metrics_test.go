@@ -0,0 +1,298 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// respondingGetIssue answers with a response of a status
+type respondingGetIssue struct {
+	status int
+}
+
+func (c respondingGetIssue) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	resp := &jira.Response{Response: &http.Response{StatusCode: c.status}}
+
+	if c.status != http.StatusOK {
+		return nil, resp, errors.New("request failed")
+	}
+
+	return &jira.Issue{Key: issueID}, resp, nil
+}
+
+func metricsText(metrics *Metrics) string {
+	buffer := &bytes.Buffer{}
+	_, err := metrics.WriteTo(buffer)
+	Expect(err).To(BeNil())
+
+	return buffer.String()
+}
+
+var _ = Describe("Metrics", func() {
+	var subject *Metrics
+
+	BeforeEach(func() {
+		subject = NewMetrics()
+	})
+
+	It("Counts renders by source and outcome", func() {
+		subject.ObserveRender("api", RenderSuccess, 20*time.Millisecond)
+		subject.ObserveRender("api", RenderSuccess, 2*time.Second)
+		subject.ObserveRender("webhook", RenderNotFound, 0)
+
+		actual := metricsText(subject)
+
+		Expect(actual).To(ContainSubstring(
+			"# TYPE jira_branch_helper_renders_total counter\n" +
+				`jira_branch_helper_renders_total{source="api",outcome="success"} 2` + "\n" +
+				`jira_branch_helper_renders_total{source="webhook",outcome="not_found"} 1` + "\n",
+		))
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_render_duration_seconds_bucket{source="api",le="0.01"} 0` + "\n" +
+				`jira_branch_helper_render_duration_seconds_bucket{source="api",le="0.025"} 1` + "\n",
+		))
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_render_duration_seconds_bucket{source="api",le="2.5"} 2` + "\n",
+		))
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_render_duration_seconds_bucket{source="api",le="+Inf"} 2` + "\n" +
+				`jira_branch_helper_render_duration_seconds_sum{source="api"} 2.02` + "\n" +
+				`jira_branch_helper_render_duration_seconds_count{source="api"} 2` + "\n",
+		))
+	})
+	It("Times requests by endpoint and status", func() {
+		subject.ObserveRequest("issue", "200", 30*time.Millisecond)
+
+		Expect(metricsText(subject)).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="issue",status="200"} 1`,
+		))
+	})
+	It("Counts authentication failures", func() {
+		subject.ObserveRequest("issue", "401", 0)
+		subject.ObserveRequest("issue", "403", 0)
+		subject.ObserveRequest("issue", "404", 0)
+
+		Expect(metricsText(subject)).To(ContainSubstring(
+			"jira_branch_helper_jira_auth_failures_total 2\n",
+		))
+	})
+	It("Counts cache hits and misses", func() {
+		subject.ObserveCache(true)
+		subject.ObserveCache(true)
+		subject.ObserveCache(false)
+
+		Expect(metricsText(subject)).To(ContainSubstring(
+			`jira_branch_helper_issue_cache_requests_total{result="hit"} 2` + "\n" +
+				`jira_branch_helper_issue_cache_requests_total{result="miss"} 1` + "\n",
+		))
+	})
+	It("Escapes labels", func() {
+		subject.ObserveRender("a \"quoted\"\\source\n", RenderSuccess, 0)
+
+		Expect(metricsText(subject)).To(ContainSubstring(
+			`{source="a \"quoted\"\\source\n",outcome="success"} 1`,
+		))
+	})
+	It("Records nothing when nil", func() {
+		var metrics *Metrics
+
+		metrics.ObserveRender("api", RenderSuccess, time.Second)
+		metrics.ObserveRequest("issue", "200", time.Second)
+		metrics.ObserveCache(true)
+
+		Expect(metricsText(metrics)).To(BeEmpty())
+	})
+	It("Serves the metrics", func() {
+		recorder := httptest.NewRecorder()
+		subject.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+		Expect(recorder.Body.String()).To(ContainSubstring("# HELP"))
+	})
+})
+
+var _ = Describe("InstrumentedTransport", func() {
+	var status int
+	var metrics *Metrics
+	var client *jira.Client
+	var stop func()
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		metrics = NewMetrics()
+
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{
+					"key": "TST-123",
+					"comments": [],
+					"issues": [],
+					"total": 0
+				}`))
+			},
+		))
+		stop = server.Close
+
+		var err error
+		client, err = jira.NewClient(
+			&http.Client{Transport: &InstrumentedTransport{Metrics: metrics}},
+			server.URL+"/",
+		)
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("Times requests for issues", func() {
+		issue, _, err := IssueClient{Client: client}.Get("TST-123", nil)
+
+		Expect(err).To(BeNil())
+		Expect(issue.Key).To(Equal("TST-123"))
+		Expect(metricsText(metrics)).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="issue",status="200"} 1`,
+		))
+	})
+	It("Times requests to other endpoints", func() {
+		_, err := AssignClient{Client: client}.Myself()
+		Expect(err).To(BeNil())
+
+		_, err = LinksClient{Client: client}.GetComments("TST-123")
+		Expect(err).To(BeNil())
+
+		_, err = SearchClient{Client: client}.IssueStatuses([]string{"TST-123"})
+		Expect(err).To(BeNil())
+
+		actual := metricsText(metrics)
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="myself",status="200"} 1`,
+		))
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="issue/comment",status="200"} 1`,
+		))
+		Expect(actual).To(ContainSubstring(
+			`jira_branch_helper_jira_request_duration_seconds_count{endpoint="search",status="200"} 1`,
+		))
+	})
+	It("Records refused requests to any endpoint", func() {
+		status = http.StatusUnauthorized
+
+		_, err := AssignClient{Client: client}.Myself()
+
+		Expect(err).ToNot(BeNil())
+		Expect(metricsText(metrics)).To(ContainSubstring(
+			"jira_branch_helper_jira_auth_failures_total 1\n",
+		))
+	})
+	It("Records requests without a response as errors", func() {
+		stop()
+
+		_, _, _ = IssueClient{Client: client}.Get("TST-123", nil)
+
+		Expect(metricsText(metrics)).To(ContainSubstring(
+			`{endpoint="issue",status="error"} 1`,
+		))
+	})
+})
+
+var _ = Describe("CachedIssueClient metrics", func() {
+	It("Counts cache hits", func() {
+		calls := 0
+		metrics := NewMetrics()
+		subject := &CachedIssueClient{
+			Client:  countingGetIssue{calls: &calls},
+			Metrics: metrics,
+		}
+
+		_, _, _ = subject.Get("TST-123", nil)
+		_, _, _ = subject.Get("TST-123", nil)
+		_, _, _ = subject.Get("TST-123", nil)
+
+		Expect(metricsText(metrics)).To(ContainSubstring(
+			`jira_branch_helper_issue_cache_requests_total{result="hit"} 2` + "\n" +
+				`jira_branch_helper_issue_cache_requests_total{result="miss"} 1` + "\n",
+		))
+	})
+})
+
+var _ = Describe("BranchServer metrics", func() {
+	var subject *BranchServer
+
+	BeforeEach(func() {
+		helper := &Jira{Client: respondingGetIssue{status: http.StatusOK}}
+		templ, err := helper.ParseTemplate("{{.Key | ToLower}}")
+		Expect(err).To(BeNil())
+
+		subject = helper.NewBranchServer(templ)
+		subject.ErrorLog = log.New(ioutil.Discard, "", 0)
+	})
+
+	get := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		subject.ServeHTTP(recorder, httptest.NewRequest("GET", target, nil))
+
+		return recorder
+	}
+
+	It("Serves no metrics without them", func() {
+		Expect(get("/metrics").Code).To(Equal(http.StatusNotFound))
+	})
+	It("Counts branch names served", func() {
+		subject.Metrics = NewMetrics()
+		subject.AllowTemplates = true
+
+		get("/branch/TST-123")
+		get("/branch/not-a-key")
+		get("/branch/TST-123?template=%7B%7B")
+		recorder := get("/metrics")
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		lines := strings.Split(recorder.Body.String(), "\n")
+		Expect(lines).To(ContainElement(
+			`jira_branch_helper_renders_total{source="api",outcome="success"} 1`,
+		))
+		Expect(lines).To(ContainElement(
+			`jira_branch_helper_renders_total{source="api",outcome="bad_request"} 1`,
+		))
+		Expect(lines).To(ContainElement(
+			`jira_branch_helper_renders_total{source="api",outcome="template_error"} 1`,
+		))
+	})
+})
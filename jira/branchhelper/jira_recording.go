@@ -0,0 +1,348 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// redacted replaces credentials in recordings
+const redacted = "REDACTED"
+
+// sensitiveNames are parts of the names of JSON keys and query parameters
+// whose values are credentials, and are never recorded
+var sensitiveNames = []string{"password", "token", "secret", "session"}
+
+// recordedHeaders are the only response headers recorded, as the others can
+// carry cookies
+var recordedHeaders = []string{"Content-Type"}
+
+// RecordedRequest is a request without its credentials. The URL is only the
+// path and query, so it can be replayed against any endpoint
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response without its cookies
+type RecordedResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Header     map[string]string `json:"header,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// Interaction is a request and the response to it
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// Recording is the requests made to Jira, and Jira's responses, with the
+// credentials taken out so it can be shared
+type Recording struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadRecording read a recording from a file
+func LoadRecording(path string) (*Recording, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read recording")
+	}
+
+	recording := &Recording{}
+	if err := json.Unmarshal(raw, recording); err != nil {
+		return nil, errors.Wrap(err, "failed to parse recording")
+	}
+
+	return recording, nil
+}
+
+// Save write a recording to a file
+func (r *Recording) Save(path string) error {
+	raw, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode recording")
+	}
+
+	if err := ioutil.WriteFile(path, append(raw, '\n'), 0600); err != nil {
+		return errors.Wrap(err, "failed to write recording")
+	}
+
+	return nil
+}
+
+// RecordingTransport records each request and response to a file, without
+// credentials, so a run can be replayed elsewhere with ReplayTransport. The
+// file is written after every response, so runs that fail or are interrupted
+// are recorded too
+type RecordingTransport struct {
+	Transport http.RoundTripper
+	Path      string
+
+	mutex     sync.Mutex
+	recording Recording
+}
+
+// RoundTrip make a request, recording it and the response
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response to record")
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    recordedURL(req.URL),
+			Body:   string(redactBody(requestBody)),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     recordedHeader(resp.Header),
+			Body:       string(redactBody(responseBody)),
+		},
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.recording.Interactions = append(t.recording.Interactions, interaction)
+
+	if err := t.recording.Save(t.Path); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ReplayTransport answers requests from a recording, without contacting
+// Jira. Requests are matched on their method and URL, and each recorded
+// response is used once, in order, apart from the last for each request,
+// which answers any repeats
+type ReplayTransport struct {
+	Recording *Recording
+
+	mutex sync.Mutex
+	used  map[int]bool
+}
+
+// RoundTrip answer a request from the recording
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	interaction, ok := t.find(req.Method, recordedURL(req.URL))
+	if !ok {
+		return nil, errors.Errorf(
+			"no recorded response for %s %s",
+			req.Method,
+			recordedURL(req.URL),
+		)
+	}
+
+	header := http.Header{}
+	for name, value := range interaction.Response.Header {
+		header.Set(name, value)
+	}
+
+	return &http.Response{
+		Status: strconv.Itoa(interaction.Response.StatusCode) + " " +
+			http.StatusText(interaction.Response.StatusCode),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (t *ReplayTransport) find(method string, target string) (Interaction, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.used == nil {
+		t.used = map[int]bool{}
+	}
+
+	last := -1
+
+	for i, interaction := range t.Recording.Interactions {
+		if interaction.Request.Method != method || interaction.Request.URL != target {
+			continue
+		}
+
+		if !t.used[i] {
+			t.used[i] = true
+
+			return interaction, true
+		}
+
+		last = i
+	}
+
+	if last < 0 {
+		return Interaction{}, false
+	}
+
+	return t.Recording.Interactions[last], true
+}
+
+// readRequestBody read the body of a request, leaving it to be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	_ = req.Body.Close()
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read request to record")
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// recordedURL the path and query of a URL, without credentials
+func recordedURL(requestURL *url.URL) string {
+	query := requestURL.Query()
+
+	for name := range query {
+		if isSensitive(name) {
+			query.Set(name, redacted)
+		}
+	}
+
+	recorded := url.URL{Path: requestURL.Path, RawQuery: query.Encode()}
+
+	return recorded.RequestURI()
+}
+
+func recordedHeader(header http.Header) map[string]string {
+	recorded := map[string]string{}
+
+	for _, name := range recordedHeaders {
+		if value := header.Get(name); value != "" {
+			recorded[name] = value
+		}
+	}
+
+	return recorded
+}
+
+// redactBody replace credentials in JSON bodies. Other bodies are kept as
+// they are
+func redactBody(body []byte) []byte {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	if len(body) == 0 || decoder.Decode(&value) != nil {
+		return body
+	}
+
+	redactedBody, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return body
+	}
+
+	return redactedBody
+}
+
+func redactValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range typed {
+			if isSensitive(key) {
+				typed[key] = redactAll(nested)
+			} else {
+				typed[key] = redactValue(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = redactValue(nested)
+		}
+	}
+
+	return value
+}
+
+// redactAll replace every value, keeping the shape of objects and lists so
+// they can still be decoded, e.g. the session Jira gives out for logging in
+func redactAll(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range typed {
+			typed[key] = redactAll(nested)
+		}
+
+		return typed
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = redactAll(nested)
+		}
+
+		return typed
+	}
+
+	return redacted
+}
+
+func isSensitive(name string) bool {
+	lower := strings.ToLower(name)
+
+	for _, sensitive := range sensitiveNames {
+		if strings.Contains(lower, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
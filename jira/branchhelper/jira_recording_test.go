@@ -0,0 +1,177 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/PurpleBooth/jira-branch-helper/jira/branchhelper/branchhelpertest"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Recording", func() {
+	var dir string
+	var path string
+	var server *branchhelpertest.Server
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "jira-branch-helper-recording")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "recording.json")
+
+		server = branchhelpertest.NewServer()
+		server.AddIssue("TST-123", map[string]interface{}{
+			"summary": "Implement the login page",
+		})
+	})
+
+	AfterEach(func() {
+		server.Close()
+		_ = os.RemoveAll(dir)
+	})
+
+	recordingClient := func(transport http.RoundTripper) *jira.Client {
+		client, err := jira.NewClient(
+			&http.Client{Transport: &RecordingTransport{
+				Transport: transport,
+				Path:      path,
+			}},
+			server.URL,
+		)
+		Expect(err).To(BeNil())
+
+		return client
+	}
+
+	replayClient := func() *jira.Client {
+		recording, err := LoadRecording(path)
+		Expect(err).To(BeNil())
+
+		client, err := jira.NewClient(
+			&http.Client{Transport: &ReplayTransport{Recording: recording}},
+			"https://jira.example.com/",
+		)
+		Expect(err).To(BeNil())
+
+		return client
+	}
+
+	recorded := func() string {
+		raw, err := ioutil.ReadFile(path)
+		Expect(err).To(BeNil())
+
+		return string(raw)
+	}
+
+	It("Replays what was recorded", func() {
+		server.RequireBasicAuth("user", "secret")
+		client := recordingClient(&jira.BasicAuthTransport{
+			Username: "user",
+			Password: "secret",
+		})
+
+		expected, err := NewJira(client).FormatIssue(
+			"TST-123",
+			"{{.Key}}-{{.Fields.Summary | KebabCase}}",
+		)
+		Expect(err).To(BeNil())
+		server.Close()
+
+		actual, err := NewJira(replayClient()).FormatIssue(
+			"TST-123",
+			"{{.Key}}-{{.Fields.Summary | KebabCase}}",
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal(expected))
+		Expect(recorded()).ToNot(ContainSubstring("secret"))
+		Expect(recorded()).ToNot(ContainSubstring("Authorization"))
+	})
+	It("Doesn't record sessions", func() {
+		server.RequireSession("user", "secret")
+		client := recordingClient(http.DefaultTransport)
+
+		_, err := client.Authentication.AcquireSessionCookie("user", "secret")
+		Expect(err).To(BeNil())
+
+		Expect(recorded()).ToNot(ContainSubstring("secret"))
+		Expect(recorded()).ToNot(ContainSubstring("branchhelpertest-session"))
+		Expect(recorded()).ToNot(ContainSubstring(branchhelpertest.SessionCookie))
+
+		_, err = replayClient().Authentication.AcquireSessionCookie("user", "secret")
+		Expect(err).To(BeNil())
+	})
+	It("Doesn't record tokens in URLs", func() {
+		client := recordingClient(http.DefaultTransport)
+		req, err := client.NewRequest("GET", "rest/api/2/myself?token=abc123", nil)
+		Expect(err).To(BeNil())
+
+		_, _ = client.Do(req, nil)
+
+		Expect(recorded()).ToNot(ContainSubstring("abc123"))
+		Expect(recorded()).To(ContainSubstring("token=REDACTED"))
+	})
+	It("Replays responses in order, repeating the last", func() {
+		server.Fail("issue/TST-123", http.StatusServiceUnavailable, 1)
+		client := recordingClient(http.DefaultTransport)
+
+		_, _, err := IssueClient{Client: client}.Get("TST-123", nil)
+		Expect(err).ToNot(BeNil())
+		_, _, err = IssueClient{Client: client}.Get("TST-123", nil)
+		Expect(err).To(BeNil())
+
+		replay := replayClient()
+
+		_, resp, err := IssueClient{Client: replay}.Get("TST-123", nil)
+		Expect(err).ToNot(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+		for i := 0; i < 2; i++ {
+			issue, _, err := IssueClient{Client: replay}.Get("TST-123", nil)
+			Expect(err).To(BeNil())
+			Expect(issue.Key).To(Equal("TST-123"))
+		}
+	})
+	It("Fails requests that weren't recorded", func() {
+		_, _, err := IssueClient{Client: recordingClient(http.DefaultTransport)}.Get(
+			"TST-123",
+			nil,
+		)
+		Expect(err).To(BeNil())
+
+		_, _, err = IssueClient{Client: replayClient()}.Get("TST-124", nil)
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(
+			"no recorded response for GET /rest/api/2/issue/TST-124",
+		))
+	})
+	It("Fails to load recordings that aren't there", func() {
+		_, err := LoadRecording(filepath.Join(dir, "missing.json"))
+
+		Expect(err).ToNot(BeNil())
+	})
+})
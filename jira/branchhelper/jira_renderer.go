@@ -0,0 +1,129 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"sync"
+	"text/template"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// Renderer generates branch names for issues you already have, from a template
+// parsed once. It is safe for concurrent use. The template it is made from
+// should not be executed or changed afterwards
+type Renderer struct {
+	template *template.Template
+	helper   *Jira
+	mutex    sync.Mutex
+}
+
+// NewRenderer a renderer for a template, which can't fetch anything from Jira.
+// Fields are looked up from the names an issue was expanded with, and related
+// issues like .Parent are errors
+func NewRenderer(templ *template.Template) *Renderer {
+	return (&Jira{}).NewRenderer(templ)
+}
+
+// NewRenderer a renderer for a template, which uses Jira to look up fields
+// and fetch related issues
+func (helper *Jira) NewRenderer(templ *template.Template) *Renderer {
+	return &Renderer{template: templ, helper: helper}
+}
+
+// Render generate a branch name for an issue
+func (r *Renderer) Render(issue *jira.Issue) (string, error) {
+	return r.RenderContext(context.Background(), issue)
+}
+
+// RenderContext generate a branch name for an issue, giving up on anything
+// fetched from Jira when the context is done
+func (r *Renderer) RenderContext(
+	ctx context.Context,
+	issue *jira.Issue,
+) (string, error) {
+	return r.execute(
+		r.helper.fieldFunction(ctx, issue),
+		r.helper.templateData(ctx, issue),
+	)
+}
+
+// RenderTrackerIssue generate a branch name for an issue from any tracker.
+// Only the details on Issue can be used, the Field function is an error
+func (r *Renderer) RenderTrackerIssue(issue *Issue) (string, error) {
+	return r.execute(unboundTrackerFieldFunction, issue)
+}
+
+func (r *Renderer) execute(
+	fieldFunction func(string) (string, error),
+	data interface{},
+) (string, error) {
+	templ, err := r.clone()
+	if err != nil {
+		return "", errors.Wrap(
+			err,
+			"failed to copy branch template",
+		)
+	}
+
+	templ.Funcs(template.FuncMap{"Field": fieldFunction})
+
+	buffer := &bytes.Buffer{}
+	writer := bufio.NewWriter(buffer)
+
+	if err := templ.Execute(writer, data); err != nil {
+		return "", errors.Wrap(
+			err,
+			"failed to execute branch template",
+		)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return "", errors.Wrap(
+			err,
+			"failed flush template output to buffer",
+		)
+	}
+
+	return buffer.String(), nil
+}
+
+// unboundTrackerFieldFunction stands in for the Field function for issues
+// that aren't from Jira
+func unboundTrackerFieldFunction(name string) (string, error) {
+	return "", errors.New("fields can only be looked up for jira issues")
+}
+
+// clone copy the template, so each render can have its own Field function
+// without changing the template another render is using
+func (r *Renderer) clone() (*template.Template, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.template.Clone()
+}
+
+// queryOptions the smallest query that fetches everything the template uses
+func (r *Renderer) queryOptions(ctx context.Context) *jira.GetQueryOptions {
+	return r.helper.queryOptions(ctx, r.template)
+}
@@ -0,0 +1,116 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"fmt"
+	"sync"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Renderer", func() {
+	namedIssue := func(number int) *jira.Issue {
+		return &jira.Issue{
+			Key:   fmt.Sprintf("TST-%d", number),
+			Names: map[string]string{"customfield_10011": "Epic Name"},
+			Fields: &jira.IssueFields{
+				Summary: fmt.Sprintf("Issue number %d", number),
+				Unknowns: map[string]interface{}{
+					"customfield_10011": fmt.Sprintf("Epic %d", number),
+				},
+			},
+		}
+	}
+
+	It("Renders issues it is given", func() {
+		templ, err := (&Jira{}).ParseTemplate("{{.Key}}-{{.Fields.Summary | KebabCase}}")
+		Expect(err).To(BeNil())
+
+		subject := NewRenderer(templ)
+
+		for _, number := range []int{1, 2} {
+			actual, err := subject.Render(namedIssue(number))
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(fmt.Sprintf("TST-%d-issue-number-%d", number, number)))
+		}
+	})
+	It("Looks up fields from the issue names", func() {
+		templ, err := (&Jira{}).ParseTemplate(`{{Field "Epic Name" | KebabCase}}`)
+		Expect(err).To(BeNil())
+
+		actual, err := NewRenderer(templ).Render(namedIssue(1))
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("epic-1"))
+	})
+	It("Can't fetch related issues without a client", func() {
+		issue := namedIssue(1)
+		issue.Fields.Parent = &jira.Parent{Key: "TST-2"}
+		templ, err := (&Jira{}).ParseTemplate("{{with .Parent}}{{.Key}}{{end}}")
+		Expect(err).To(BeNil())
+
+		_, err = NewRenderer(templ).Render(issue)
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Looks up fields with Jira", func() {
+		helper := fieldsJira()
+		templ, err := helper.ParseTemplate(`{{Field "Team"}}`)
+		Expect(err).To(BeNil())
+
+		actual, err := helper.NewRenderer(templ).Render(testIssue())
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("Platform"))
+	})
+	It("Renders concurrently", func() {
+		templ, err := (&Jira{}).ParseTemplate(
+			`{{.Key}} {{Field "Epic Name"}} {{.Fields.Summary}}`,
+		)
+		Expect(err).To(BeNil())
+
+		subject := NewRenderer(templ)
+		results := make([]string, 50)
+		errs := make([]error, 50)
+		wait := sync.WaitGroup{}
+
+		for i := range results {
+			wait.Add(1)
+
+			go func(i int) {
+				defer wait.Done()
+				results[i], errs[i] = subject.Render(namedIssue(i))
+			}(i)
+		}
+
+		wait.Wait()
+
+		for i := range results {
+			Expect(errs[i]).To(BeNil())
+			Expect(results[i]).To(Equal(
+				fmt.Sprintf("TST-%d Epic %d Issue number %d", i, i, i),
+			))
+		}
+	})
+})
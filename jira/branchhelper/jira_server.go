@@ -0,0 +1,304 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// Where branch names are asked for, for metrics
+const (
+	renderSourceAPI     = "api"
+	renderSourceWebhook = "webhook"
+)
+
+// issueKeyReg matches issue keys like "TST-123", or issue IDs like "10000",
+// so nothing but an issue can be asked for from Jira
+var issueKeyReg = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*-)?[0-9]+$`)
+
+// BranchServer serves branch names over HTTP, for tools that want to suggest
+// a branch name for an issue. GET /branch/{key} answers with the branch name
+// for an issue as JSON, e.g. {"key": "TST-123", "branch": "tst-123-title"},
+// from another template if one is given with ?template= and AllowTemplates is
+// set. /healthz is ok while
+// the server is running, and /readyz while it can reach Jira. With a
+// WebhookSecret, Jira webhooks for created and updated issues can be sent to
+// POST /webhook, which builds the branch name from the issue in the webhook
+type BranchServer struct {
+	// AllowTemplates lets callers give their own templates with ?template=.
+	// Templates can read any field of any issue the server can see, so they
+	// are refused unless this is set
+	AllowTemplates bool
+	// Includes are patterns of files with templates that templates given
+	// with ?template= can use
+	Includes []string
+	// Ready checks Jira can be reached, the server is always ready if nil
+	Ready func(ctx context.Context) error
+	// WebhookSecret is the secret webhooks are signed with, or sent with as
+	// ?secret=, webhooks aren't accepted if it is empty
+	WebhookSecret string
+	// CommentBranch comments the branch name built for a webhook on the
+	// issue, nothing is commented if nil
+	CommentBranch CommentBranchFunc
+	// Metrics counts the branch names built, they are served at /metrics
+	// if not nil
+	Metrics *Metrics
+	// ErrorLog is where failures are logged, the log package's standard
+	// logger if nil
+	ErrorLog *log.Logger
+
+	helper   *Jira
+	renderer *Renderer
+	mux      *http.ServeMux
+	draining int32
+}
+
+// branchResponse is the answer to a request for a branch name
+type branchResponse struct {
+	Key    string `json:"key"`
+	Branch string `json:"branch"`
+}
+
+// statusResponse is the answer to health checks and failed requests
+type statusResponse struct {
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewBranchServer a server for branch names from a template, using Jira to
+// get the issues. Wrap the issue client in a CachedIssueClient to avoid
+// asking Jira about the same issue again and again
+func (helper *Jira) NewBranchServer(templ *template.Template) *BranchServer {
+	server := &BranchServer{
+		helper:   helper,
+		renderer: helper.NewRenderer(templ),
+		mux:      http.NewServeMux(),
+	}
+
+	server.mux.HandleFunc("/branch/", server.serveBranch)
+	server.mux.HandleFunc("/healthz", server.serveHealth)
+	server.mux.HandleFunc("/readyz", server.serveReady)
+	server.mux.HandleFunc("/webhook", server.serveWebhook)
+	server.mux.HandleFunc("/metrics", server.serveMetrics)
+
+	return server
+}
+
+// Drain mark the server as not ready, so it is taken out of service before it
+// is shut down. Branch names are still served
+func (s *BranchServer) Drain() {
+	atomic.StoreInt32(&s.draining, 1)
+}
+
+// ServeHTTP answer a request
+func (s *BranchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *BranchServer) serveBranch(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/branch/")
+	if !issueKeyReg.MatchString(key) {
+		s.Metrics.ObserveRender(renderSourceAPI, RenderBadRequest, 0)
+		writeJSON(w, http.StatusBadRequest, statusResponse{
+			Error: "not an issue key: " + key,
+		})
+
+		return
+	}
+
+	renderer := s.renderer
+
+	if raw, ok := r.URL.Query()["template"]; ok {
+		if !s.AllowTemplates {
+			s.Metrics.ObserveRender(renderSourceAPI, RenderBadRequest, 0)
+			writeJSON(w, http.StatusForbidden, statusResponse{
+				Error: "templates can't be given with ?template= on this server",
+			})
+
+			return
+		}
+
+		templ, err := s.helper.ParseTemplate(strings.Join(raw, ""), s.Includes...)
+
+		if err != nil {
+			s.Metrics.ObserveRender(renderSourceAPI, RenderTemplateError, 0)
+			writeJSON(w, http.StatusBadRequest, statusResponse{
+				Error: err.Error(),
+			})
+
+			return
+		}
+
+		renderer = s.helper.NewRenderer(templ)
+	}
+
+	ctx := r.Context()
+	issue, err := s.helper.getIssue(ctx, key, renderer.queryOptions(ctx))
+
+	if err != nil {
+		s.writeRequestError(w, key, err, 0)
+
+		return
+	}
+
+	start := time.Now()
+	branch, err := renderer.RenderContext(ctx, issue)
+	duration := time.Since(start)
+
+	if err != nil {
+		if failedRequest(ctx, err) {
+			s.writeRequestError(w, key, err, duration)
+		} else {
+			s.Metrics.ObserveRender(renderSourceAPI, RenderTemplateError, duration)
+			s.logf("failed to build a branch name for %s: %v", key, requestCause(err))
+			writeJSON(w, http.StatusUnprocessableEntity, statusResponse{
+				Error: "failed to build a branch name from the template",
+			})
+		}
+
+		return
+	}
+
+	s.Metrics.ObserveRender(renderSourceAPI, RenderSuccess, duration)
+	writeJSON(w, http.StatusOK, branchResponse{Key: key, Branch: branch})
+}
+
+// writeRequestError answer with what went wrong asking Jira. The details are
+// only logged, as they include the credentials used
+func (s *BranchServer) writeRequestError(
+	w http.ResponseWriter,
+	key string,
+	err error,
+	duration time.Duration,
+) {
+	if requestStatusCode(err) == http.StatusNotFound {
+		s.Metrics.ObserveRender(renderSourceAPI, RenderNotFound, duration)
+		writeJSON(w, http.StatusNotFound, statusResponse{
+			Error: "issue not found: " + key,
+		})
+
+		return
+	}
+
+	s.Metrics.ObserveRender(renderSourceAPI, RenderJiraError, duration)
+	s.logf("failed to get %s from jira: %v", key, requestCause(err))
+	writeJSON(w, http.StatusBadGateway, statusResponse{
+		Error: "failed to get issue from jira",
+	})
+}
+
+func (s *BranchServer) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.Metrics == nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if !allowGet(w, r) {
+		return
+	}
+
+	s.Metrics.ServeHTTP(w, r)
+}
+
+func (s *BranchServer) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func (s *BranchServer) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+
+	if atomic.LoadInt32(&s.draining) != 0 {
+		writeJSON(w, http.StatusServiceUnavailable, statusResponse{
+			Status: "draining",
+		})
+
+		return
+	}
+
+	if s.Ready != nil {
+		if err := s.Ready(r.Context()); err != nil {
+			s.logf("not ready: %v", requestCause(err))
+			writeJSON(w, http.StatusServiceUnavailable, statusResponse{
+				Status: "not ready",
+				Error:  "can't reach jira",
+			})
+
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{Status: "ok"})
+}
+
+func (s *BranchServer) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+
+		return
+	}
+
+	log.Printf(format, args...)
+}
+
+// failedRequest if building a branch name failed because a request to Jira
+// did, rather than the template
+func failedRequest(ctx context.Context, err error) bool {
+	_, ok := asRequestError(err)
+
+	return ok || ctx.Err() != nil
+}
+
+// allowGet only allow requests that read, answering anything else
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+
+	w.Header().Set("Allow", "GET, HEAD")
+	writeJSON(w, http.StatusMethodNotAllowed, statusResponse{
+		Error: "method not allowed",
+	})
+
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,258 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BranchServer", func() {
+	var jiraServer *httptest.Server
+	var jiraRequests []*http.Request
+	var jiraStatus int
+	var fieldsStatus int
+	var subject *BranchServer
+	var helper *Jira
+
+	BeforeEach(func() {
+		jiraRequests = []*http.Request{}
+		jiraStatus = http.StatusOK
+		fieldsStatus = http.StatusOK
+		jiraServer = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				jiraRequests = append(jiraRequests, r)
+				w.Header().Set("Content-Type", "application/json")
+
+				if r.URL.Path == "/rest/api/2/field" {
+					w.WriteHeader(fieldsStatus)
+					_, _ = w.Write([]byte(`[]`))
+
+					return
+				}
+
+				w.WriteHeader(jiraStatus)
+				_, _ = w.Write([]byte(`{
+					"key": "TST-123",
+					"fields": {
+						"summary": "Implement the login page",
+						"labels": ["frontend"]
+					}
+				}`))
+			},
+		))
+
+		client, err := jira.NewClient(nil, jiraServer.URL+"/")
+		Expect(err).To(BeNil())
+
+		helper = NewJira(client)
+		helper.Client = &CachedIssueClient{Client: helper.Client}
+	})
+
+	JustBeforeEach(func() {
+		templ, err := helper.ParseTemplate(
+			"{{.Key | ToLower}}-{{.Fields.Summary | KebabCase}}",
+		)
+		Expect(err).To(BeNil())
+
+		subject = helper.NewBranchServer(templ)
+		subject.ErrorLog = log.New(ioutil.Discard, "", 0)
+	})
+
+	AfterEach(func() {
+		jiraServer.Close()
+	})
+
+	serve := func(method string, target string) (int, map[string]string) {
+		recorder := httptest.NewRecorder()
+		subject.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+
+		body := map[string]string{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		return recorder.Code, body
+	}
+
+	It("Serves branch names", func() {
+		status, body := serve("GET", "/branch/TST-123")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(map[string]string{
+			"key":    "TST-123",
+			"branch": "tst-123-implement-the-login-page",
+		}))
+		Expect(jiraRequests[0].URL.Path).To(Equal("/rest/api/2/issue/TST-123"))
+	})
+	It("Remembers issues", func() {
+		serve("GET", "/branch/TST-123")
+		serve("GET", "/branch/TST-123")
+
+		Expect(jiraRequests).To(HaveLen(1))
+	})
+	It("Refuses other templates unless allowed", func() {
+		status, body := serve(
+			"GET",
+			"/branch/TST-123?template="+url.QueryEscape(`{{.Fields.Description}}`),
+		)
+
+		Expect(status).To(Equal(http.StatusForbidden))
+		Expect(body["error"]).ToNot(BeEmpty())
+		Expect(jiraRequests).To(BeEmpty())
+	})
+	Context("With templates allowed", func() {
+		JustBeforeEach(func() {
+			subject.AllowTemplates = true
+		})
+
+		It("Serves branch names from other templates", func() {
+			status, body := serve(
+				"GET",
+				"/branch/TST-123?template="+url.QueryEscape(
+					`{{.Labels | Join "-"}}/{{.Key}}`,
+				),
+			)
+
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body["branch"]).To(Equal("frontend/TST-123"))
+		})
+		It("Rejects templates that don't parse", func() {
+			status, body := serve("GET", "/branch/TST-123?template=%7B%7B")
+
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(body["error"]).ToNot(BeEmpty())
+			Expect(jiraRequests).To(BeEmpty())
+		})
+		Context("With credentials", func() {
+			BeforeEach(func() {
+				client, err := jira.NewClient(
+					(&jira.BasicAuthTransport{Username: "user", Password: "secret"}).Client(),
+					jiraServer.URL+"/",
+				)
+				Expect(err).To(BeNil())
+
+				helper = NewJira(client)
+			})
+
+			It("Rejects templates that fail", func() {
+				status, body := serve(
+					"GET",
+					"/branch/TST-123?template="+url.QueryEscape(`{{Field "Missing"}}`),
+				)
+
+				Expect(status).To(Equal(http.StatusUnprocessableEntity))
+				Expect(body["error"]).ToNot(BeEmpty())
+				Expect(body["error"]).ToNot(ContainSubstring("Missing"))
+			})
+			It("Doesn't show requests that fail in templates", func() {
+				fieldsStatus = http.StatusInternalServerError
+
+				status, body := serve(
+					"GET",
+					"/branch/TST-123?template="+url.QueryEscape(`{{Field "Team"}}`),
+				)
+
+				Expect(status).To(Equal(http.StatusBadGateway))
+				Expect(body["error"]).ToNot(ContainSubstring("Authorization"))
+				Expect(body["error"]).ToNot(ContainSubstring("/rest/api/2/field"))
+				Expect(body["error"]).ToNot(ContainSubstring("secret"))
+			})
+		})
+	})
+	It("Only asks Jira for issues", func() {
+		status, _ := serve("GET", "/branch/..%2Fmyself")
+
+		Expect(status).To(Equal(http.StatusBadRequest))
+		Expect(jiraRequests).To(BeEmpty())
+	})
+	It("Only answers GET requests", func() {
+		status, _ := serve("POST", "/branch/TST-123")
+
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+	})
+	Context("When the issue doesn't exist", func() {
+		BeforeEach(func() {
+			jiraStatus = http.StatusNotFound
+		})
+
+		It("Is not found", func() {
+			status, body := serve("GET", "/branch/TST-123")
+
+			Expect(status).To(Equal(http.StatusNotFound))
+			Expect(body["error"]).To(Equal("issue not found: TST-123"))
+		})
+	})
+	Context("When Jira is failing", func() {
+		BeforeEach(func() {
+			jiraStatus = http.StatusInternalServerError
+		})
+
+		It("Is a bad gateway", func() {
+			status, body := serve("GET", "/branch/TST-123")
+
+			Expect(status).To(Equal(http.StatusBadGateway))
+			Expect(body["error"]).To(Equal("failed to get issue from jira"))
+		})
+	})
+	It("Is healthy", func() {
+		status, body := serve("GET", "/healthz")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body["status"]).To(Equal("ok"))
+	})
+	It("Is ready", func() {
+		subject.Ready = func(ctx context.Context) error { return nil }
+
+		status, _ := serve("GET", "/readyz")
+
+		Expect(status).To(Equal(http.StatusOK))
+	})
+	It("Isn't ready when Jira can't be reached", func() {
+		subject.Ready = func(ctx context.Context) error {
+			return errors.New("connection refused")
+		}
+
+		status, body := serve("GET", "/readyz")
+
+		Expect(status).To(Equal(http.StatusServiceUnavailable))
+		Expect(body["status"]).To(Equal("not ready"))
+	})
+	It("Isn't ready while draining", func() {
+		subject.Drain()
+
+		readyStatus, _ := serve("GET", "/readyz")
+		healthStatus, _ := serve("GET", "/healthz")
+		branchStatus, _ := serve("GET", "/branch/TST-123")
+
+		Expect(readyStatus).To(Equal(http.StatusServiceUnavailable))
+		Expect(healthStatus).To(Equal(http.StatusOK))
+		Expect(branchStatus).To(Equal(http.StatusOK))
+	})
+})
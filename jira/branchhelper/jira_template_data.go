@@ -0,0 +1,276 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// epicLinkField and sprintField are the display names of the Jira Software
+// custom fields that link an issue to its epic and sprints
+const (
+	epicLinkField = "Epic Link"
+	sprintField   = "Sprint"
+)
+
+// legacySprintReg matches the fields of sprints as older versions of Jira
+// Software print them, e.g. "com.atlassian...Sprint@1a[id=1,state=ACTIVE]"
+var legacySprintReg = regexp.MustCompile(`(\w+)=([^,\]]*)`)
+
+// TemplateData is what branch templates are executed against. It is the Jira
+// issue, plus related issues that are only fetched from Jira if the template
+// uses them
+type TemplateData struct {
+	*jira.Issue
+
+	ctx    context.Context
+	helper *Jira
+	parent lazyIssue
+	epic   lazyIssue
+}
+
+// Sprint is the sprint an issue is in
+type Sprint struct {
+	ID    int
+	Name  string
+	State string
+}
+
+type lazyIssue struct {
+	fetched bool
+	issue   *TemplateData
+	err     error
+}
+
+func (l *lazyIssue) get(
+	fetch func() (*TemplateData, error),
+) (*TemplateData, error) {
+	if !l.fetched {
+		l.issue, l.err = fetch()
+		l.fetched = true
+	}
+
+	return l.issue, l.err
+}
+
+// templateData the data for an issue. The context is kept so related issues
+// fetched while the template is executed can be cancelled too
+func (helper *Jira) templateData(
+	ctx context.Context,
+	issue *jira.Issue,
+) *TemplateData {
+	return &TemplateData{Issue: issue, ctx: ctx, helper: helper}
+}
+
+func (helper *Jira) fetchTemplateData(
+	ctx context.Context,
+	issueID string,
+) (*TemplateData, error) {
+	issue, err := helper.getIssue(ctx, issueID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return helper.templateData(ctx, issue), nil
+}
+
+// Title the summary of the issue, as it is called on other trackers
+func (data *TemplateData) Title() string {
+	if data.Fields == nil {
+		return ""
+	}
+
+	return data.Fields.Summary
+}
+
+// Type the name of the type of the issue, e.g. "Story"
+func (data *TemplateData) Type() string {
+	if data.Fields == nil {
+		return ""
+	}
+
+	return data.Fields.Type.Name
+}
+
+// Labels the labels on the issue
+func (data *TemplateData) Labels() []string {
+	if data.Fields == nil || data.Fields.Labels == nil {
+		return []string{}
+	}
+
+	return data.Fields.Labels
+}
+
+// URL the link to the issue in Jira
+func (data *TemplateData) URL() string {
+	return jiraIssueURL(data.Issue)
+}
+
+// Parent the issue a sub-task belongs to, or nil for other issues
+func (data *TemplateData) Parent() (*TemplateData, error) {
+	return data.parent.get(func() (*TemplateData, error) {
+		if data.Fields == nil || data.Fields.Parent == nil {
+			return nil, nil
+		}
+
+		parentID := data.Fields.Parent.Key
+		if parentID == "" {
+			parentID = data.Fields.Parent.ID
+		}
+
+		return data.helper.fetchTemplateData(data.ctx, parentID)
+	})
+}
+
+// Epic the epic the issue belongs to, or the epic of its parent for
+// sub-tasks. Nil if the issue isn't in an epic
+func (data *TemplateData) Epic() (*TemplateData, error) {
+	return data.epic.get(func() (*TemplateData, error) {
+		seen := map[string]bool{}
+
+		for issue := data; ; {
+			if seen[issue.identity()] {
+				return nil, errors.Errorf(
+					"the parents of %s loop back to %s",
+					data.identity(),
+					issue.identity(),
+				)
+			}
+			seen[issue.identity()] = true
+
+			epicKey, err := issue.epicKey()
+			if err != nil {
+				return nil, err
+			}
+
+			if epicKey != "" {
+				return data.helper.fetchTemplateData(data.ctx, epicKey)
+			}
+
+			parent, err := issue.Parent()
+			if err != nil || parent == nil {
+				return nil, err
+			}
+
+			if strings.EqualFold(parent.Type(), "Epic") {
+				return parent, nil
+			}
+
+			issue = parent
+		}
+	})
+}
+
+// identity the key of the issue, or its ID if Jira didn't give the key
+func (data *TemplateData) identity() string {
+	if data.Key != "" {
+		return data.Key
+	}
+
+	return data.ID
+}
+
+func (data *TemplateData) epicKey() (string, error) {
+	if data.Fields == nil {
+		return "", nil
+	}
+
+	if data.Fields.Epic != nil && data.Fields.Epic.Key != "" {
+		return data.Fields.Epic.Key, nil
+	}
+
+	epicLink, _, err := data.helper.fieldValue(
+		data.ctx,
+		data.Issue,
+		epicLinkField,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return fieldValueString(epicLink), nil
+}
+
+// Sprint the active sprint the issue is in, or the latest one if none are
+// active. Nil if the issue has never been in a sprint
+func (data *TemplateData) Sprint() (*Sprint, error) {
+	value, _, err := data.helper.fieldValue(data.ctx, data.Issue, sprintField)
+	if err != nil {
+		return nil, err
+	}
+
+	rawSprints, ok := value.([]interface{})
+	if !ok || len(rawSprints) == 0 {
+		return nil, nil
+	}
+
+	sprints := []*Sprint{}
+
+	for _, rawSprint := range rawSprints {
+		sprint, err := parseSprint(rawSprint)
+		if err != nil {
+			return nil, err
+		}
+
+		if strings.EqualFold(sprint.State, "active") {
+			return sprint, nil
+		}
+
+		sprints = append(sprints, sprint)
+	}
+
+	return sprints[len(sprints)-1], nil
+}
+
+// parseSprint read a sprint, either the object newer versions of Jira use, or
+// the string older ones do
+func parseSprint(rawSprint interface{}) (*Sprint, error) {
+	sprint := &Sprint{}
+
+	switch typed := rawSprint.(type) {
+	case map[string]interface{}:
+		sprint.Name = fieldValueString(typed["name"])
+		sprint.State = fieldValueString(typed["state"])
+
+		if id, ok := typed["id"].(float64); ok {
+			sprint.ID = int(id)
+		}
+	case string:
+		for _, match := range legacySprintReg.FindAllStringSubmatch(typed, -1) {
+			switch match[1] {
+			case "id":
+				sprint.ID, _ = strconv.Atoi(match[2])
+			case "name":
+				sprint.Name = match[2]
+			case "state":
+				sprint.State = match[2]
+			}
+		}
+	default:
+		return nil, errors.Errorf("unrecognised sprint %v", rawSprint)
+	}
+
+	return sprint, nil
+}
@@ -0,0 +1,268 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/json"
+	"errors"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testRelatedIssuesJSON = `{
+	"TST-100": {
+		"key": "TST-100",
+		"fields": {
+			"summary": "Login page",
+			"issuetype": {"name": "Story"},
+			"customfield_10014": "TST-1"
+		}
+	},
+	"TST-104": {
+		"key": "TST-104",
+		"fields": {
+			"summary": "Validation",
+			"issuetype": {"name": "Sub-task"},
+			"parent": {"id": "10100", "key": "TST-100"},
+			"customfield_10020": [
+				"com.atlassian.greenhopper.service.sprint.Sprint@1f[id=1,rapidViewId=2,state=CLOSED,name=Sprint 1,goal=]",
+				"com.atlassian.greenhopper.service.sprint.Sprint@2f[id=2,rapidViewId=2,state=ACTIVE,name=Sprint 2,goal=]"
+			]
+		}
+	},
+	"TST-105": {
+		"key": "TST-105",
+		"fields": {
+			"summary": "Styling",
+			"issuetype": {"name": "Story"},
+			"parent": {"id": "10001", "key": "TST-1"},
+			"customfield_10020": [
+				{"id": 3, "name": "Sprint 3", "state": "closed"},
+				{"id": 4, "name": "Sprint 4", "state": "future"}
+			]
+		}
+	},
+	"TST-106": {
+		"key": "TST-106",
+		"fields": {
+			"summary": "Hidden parent",
+			"issuetype": {"name": "Sub-task"},
+			"parent": {"id": "10002", "key": "TST-2"}
+		}
+	},
+	"TST-107": {
+		"key": "TST-107",
+		"fields": {
+			"summary": "Loop",
+			"issuetype": {"name": "Sub-task"},
+			"parent": {"id": "10108", "key": "TST-108"}
+		}
+	},
+	"TST-108": {
+		"key": "TST-108",
+		"fields": {
+			"summary": "Back again",
+			"issuetype": {"name": "Sub-task"},
+			"parent": {"id": "10107", "key": "TST-107"}
+		}
+	},
+	"TST-2": {
+		"key": "TST-2"
+	},
+	"TST-1": {
+		"key": "TST-1",
+		"fields": {
+			"summary": "Accounts",
+			"issuetype": {"name": "Epic"}
+		}
+	}
+}`
+
+var _ = Describe("TemplateData", func() {
+	var client *testIssueStore
+	var subject *Jira
+
+	BeforeEach(func() {
+		client = newTestIssueStore()
+		subject = &Jira{
+			Client: client,
+			Fields: &testGetFields{fields: []FieldMetadata{
+				{ID: "customfield_10014", Name: "Epic Link", Custom: true},
+				{ID: "customfield_10020", Name: "Sprint", Custom: true},
+			}},
+		}
+	})
+
+	Context("Parent", func() {
+		It("Names sub-tasks after their parent", func() {
+			actual, err := subject.FormatIssue(
+				"TST-104",
+				"{{with .Parent}}{{.Key | ToLower}}-{{.Fields.Summary | Slug}}/{{end}}"+
+					"{{.Key | ToLower}}-{{.Fields.Summary | Slug}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("tst-100-login-page/tst-104-validation"))
+		})
+		It("Is nil for issues without a parent", func() {
+			actual, err := subject.FormatIssue(
+				"TST-100",
+				"{{with .Parent}}{{.Key}}/{{end}}{{.Key}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("TST-100"))
+		})
+		It("Is only fetched once", func() {
+			_, err := subject.FormatIssue(
+				"TST-104",
+				"{{.Parent.Key}} {{.Parent.Fields.Summary}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(client.calls).To(Equal([]string{"TST-104", "TST-100"}))
+		})
+		It("Is not fetched unless used", func() {
+			_, err := subject.FormatIssue("TST-104", "{{.Key}}")
+
+			Expect(err).To(BeNil())
+			Expect(client.calls).To(Equal([]string{"TST-104"}))
+		})
+		It("Reports failures fetching it", func() {
+			client.err = errors.New("broken")
+			subject.Client = &testFailAfterFirst{client: client}
+
+			actual, err := subject.FormatIssue("TST-104", "{{.Parent.Key}}")
+
+			Expect(actual).To(Equal(""))
+			Expect(err).ToNot(BeNil())
+		})
+	})
+	Context("Epic", func() {
+		It("Uses the epic link", func() {
+			actual, err := subject.FormatIssue("TST-100", "{{.Epic.Fields.Summary}}")
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("Accounts"))
+		})
+		It("Uses the epic of the parent for sub-tasks", func() {
+			actual, err := subject.FormatIssue("TST-104", "{{.Epic.Key}}")
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("TST-1"))
+		})
+		It("Uses parents that are epics", func() {
+			actual, err := subject.FormatIssue("TST-105", "{{.Epic.Key}}")
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("TST-1"))
+		})
+		It("Is nil for issues without an epic", func() {
+			actual, err := subject.FormatIssue(
+				"TST-1",
+				"{{with .Epic}}{{.Key}}{{else}}none{{end}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("none"))
+		})
+		It("Is nil for parents returned without fields", func() {
+			actual, err := subject.FormatIssue(
+				"TST-106",
+				"{{with .Epic}}{{.Key}}{{else}}none{{end}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("none"))
+		})
+		It("Stops at parents that loop", func() {
+			actual, err := subject.FormatIssue("TST-107", "{{.Epic.Key}}")
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("loop"))
+			Expect(actual).To(Equal(""))
+		})
+	})
+	Context("Sprint", func() {
+		It("Uses the active sprint", func() {
+			actual, err := subject.FormatIssue(
+				"TST-104",
+				"{{.Sprint.ID}} {{.Sprint.Name}} {{.Sprint.State}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("2 Sprint 2 ACTIVE"))
+		})
+		It("Uses the latest sprint without an active one", func() {
+			actual, err := subject.FormatIssue("TST-105", "{{.Sprint.Name}}")
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("Sprint 4"))
+		})
+		It("Is nil for issues never in a sprint", func() {
+			actual, err := subject.FormatIssue(
+				"TST-100",
+				"{{with .Sprint}}{{.Name}}{{else}}backlog{{end}}",
+			)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal("backlog"))
+		})
+	})
+})
+
+type testIssueStore struct {
+	issues map[string]*jira.Issue
+	calls  []string
+	err    error
+}
+
+func newTestIssueStore() *testIssueStore {
+	issues := map[string]*jira.Issue{}
+	Expect(json.Unmarshal([]byte(testRelatedIssuesJSON), &issues)).To(Succeed())
+
+	return &testIssueStore{issues: issues}
+}
+
+func (t *testIssueStore) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	t.calls = append(t.calls, issueID)
+
+	return t.issues[issueID], nil, nil
+}
+
+type testFailAfterFirst struct {
+	client *testIssueStore
+}
+
+func (t *testFailAfterFirst) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	if len(t.client.calls) > 0 {
+		return nil, nil, t.client.err
+	}
+
+	return t.client.Get(issueID, options)
+}
@@ -0,0 +1,288 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// defaultStopWordsLanguage is the language used by RemoveStopWords when none
+// is given
+const defaultStopWordsLanguage = "en"
+
+// TemplateConfig customises the behaviour of the template functions. Anything
+// left empty uses the built in defaults
+type TemplateConfig struct {
+	// StopWords are the words RemoveStopWords drops, by language
+	StopWords map[string][]string `json:"stopWords"`
+	// Abbreviations are the replacements Abbreviate makes, by word
+	Abbreviations map[string]string `json:"abbreviations"`
+}
+
+// DefaultStopWords are the stop words used when none are configured for a
+// language
+var DefaultStopWords = map[string][]string{
+	"en": {
+		"a", "an", "and", "as", "at", "be", "by", "for", "from", "in",
+		"is", "it", "of", "on", "or", "the", "to", "with",
+	},
+	"de": {
+		"am", "an", "auf", "das", "der", "die", "ein", "eine", "für",
+		"im", "in", "ist", "mit", "oder", "und", "von", "zu", "zum",
+	},
+	"fr": {
+		"à", "au", "aux", "de", "des", "du", "en", "et", "la", "le",
+		"les", "ou", "par", "pour", "sur", "un", "une",
+	},
+	"es": {
+		"a", "al", "con", "de", "del", "el", "en", "la", "las", "los",
+		"o", "para", "por", "un", "una", "y",
+	},
+}
+
+// DefaultAbbreviations are the abbreviations used by Abbreviate, in addition
+// to any configured
+var DefaultAbbreviations = map[string]string{
+	"administrator":  "admin",
+	"application":    "app",
+	"authentication": "auth",
+	"authorisation":  "authz",
+	"authorization":  "authz",
+	"configuration":  "config",
+	"database":       "db",
+	"development":    "dev",
+	"documentation":  "docs",
+	"environment":    "env",
+	"implementation": "impl",
+	"production":     "prod",
+	"repository":     "repo",
+	"specification":  "spec",
+}
+
+// slugTransliterations are the replacements Slug makes for letters outside of
+// ASCII before dropping anything else that isn't branch safe
+var slugTransliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a",
+	'æ': "ae", 'ç': "c", 'č': "c", 'ć': "c", 'ď': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ě': "e", 'ę': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o",
+	'œ': "oe", 'ř': "r", 'ß': "ss", 'š': "s", 'ś': "s", 'ť': "t",
+	'þ': "th", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u",
+	'ý': "y", 'ÿ': "y", 'ž': "z", 'ź': "z", 'ż': "z",
+}
+
+func (config TemplateConfig) stopWords(language string) ([]string, bool) {
+	if words, ok := config.StopWords[language]; ok {
+		return words, true
+	}
+
+	words, ok := DefaultStopWords[language]
+	return words, ok
+}
+
+func (config TemplateConfig) abbreviation(word string) (string, bool) {
+	for full, short := range config.Abbreviations {
+		if strings.EqualFold(full, word) {
+			return short, true
+		}
+	}
+
+	for full, short := range DefaultAbbreviations {
+		if strings.EqualFold(full, word) {
+			return short, true
+		}
+	}
+
+	return "", false
+}
+
+// splitPunctuation separates a word from any punctuation around it
+func splitPunctuation(word string) (string, string, string) {
+	isNotWord := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+
+	trimmed := strings.TrimLeftFunc(word, isNotWord)
+	prefix := word[:len(word)-len(trimmed)]
+	core := strings.TrimRightFunc(trimmed, isNotWord)
+	suffix := trimmed[len(core):]
+
+	return prefix, core, suffix
+}
+
+func regexReplace(pattern string, with string, s string) (string, error) {
+	reg, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid regular expression")
+	}
+
+	return reg.ReplaceAllString(s, with), nil
+}
+
+func removeStopWords(config TemplateConfig) func(...string) (string, error) {
+	return func(args ...string) (string, error) {
+		language := defaultStopWordsLanguage
+
+		switch len(args) {
+		case 1:
+		case 2:
+			language = args[0]
+		default:
+			return "", errors.New(
+				"RemoveStopWords takes an optional language and a string",
+			)
+		}
+
+		stopWords, ok := config.stopWords(language)
+		if !ok {
+			return "", errors.Errorf(
+				"no stop words known for language %q",
+				language,
+			)
+		}
+
+		kept := []string{}
+
+		for _, word := range strings.Fields(args[len(args)-1]) {
+			_, core, _ := splitPunctuation(word)
+
+			if !containsFold(stopWords, core) {
+				kept = append(kept, word)
+			}
+		}
+
+		return strings.Join(kept, " "), nil
+	}
+}
+
+func containsFold(haystack []string, needle string) bool {
+	for i := range haystack {
+		if strings.EqualFold(haystack[i], needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func abbreviate(config TemplateConfig) func(string) string {
+	return func(s string) string {
+		words := strings.Fields(s)
+
+		for i, word := range words {
+			prefix, core, suffix := splitPunctuation(word)
+			short, ok := config.abbreviation(core)
+
+			if !ok {
+				continue
+			}
+
+			words[i] = prefix + matchCase(core, short) + suffix
+		}
+
+		return strings.Join(words, " ")
+	}
+}
+
+// matchCase make the replacement look like the original, if it is all upper
+// case or starts with a capital
+func matchCase(original string, replacement string) string {
+	if replacement == "" {
+		return replacement
+	}
+
+	if original == strings.ToUpper(original) {
+		return strings.ToUpper(replacement)
+	}
+
+	firstRune := []rune(original)[0]
+	if unicode.IsUpper(firstRune) {
+		runes := []rune(replacement)
+		return string(unicode.ToUpper(runes[0])) + string(runes[1:])
+	}
+
+	return replacement
+}
+
+func firstWords(n int, s string) string {
+	words := strings.Fields(s)
+
+	if n < 0 {
+		n = 0
+	}
+
+	if n < len(words) {
+		words = words[:n]
+	}
+
+	return strings.Join(words, " ")
+}
+
+func defaultValue(fallback string, s string) string {
+	if strings.TrimSpace(s) == "" {
+		return fallback
+	}
+
+	return s
+}
+
+func join(separator string, items []string) string {
+	return strings.Join(items, separator)
+}
+
+func split(separator string, s string) []string {
+	return strings.Split(s, separator)
+}
+
+// slug make a string safe to use as a branch name, lower case with words
+// separated by single dashes
+func slug(s string) string {
+	parts := []string{}
+	current := []rune{}
+
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, string(current))
+			current = []rune{}
+		}
+	}
+
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			current = append(current, r)
+		case slugTransliterations[r] != "":
+			current = append(current, []rune(slugTransliterations[r])...)
+		case r == '\'' || r == '’':
+			// Drop apostrophes so "don't" becomes "dont" not "don-t"
+		default:
+			flush()
+		}
+	}
+
+	flush()
+
+	return strings.Join(parts, "-")
+}
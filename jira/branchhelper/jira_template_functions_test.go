@@ -0,0 +1,223 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Template functions", func() {
+	It("RegexReplace", func() {
+		actual, err := formatIssue(
+			"Developments Phase 1: Implement Feature γ Bäume",
+			"{{.Fields.Summary | RegexReplace \"[0-9]+\" \"N\" }}",
+		)
+
+		Expect(actual).To(Equal("Developments Phase N: Implement Feature γ Bäume"))
+		Expect(err).To(BeNil())
+	})
+	It("RegexReplace with an invalid pattern", func() {
+		actual, err := formatIssue(
+			"Developments Phase 1: Implement Feature γ Bäume",
+			"{{.Fields.Summary | RegexReplace \"[0-9\" \"N\" }}",
+		)
+
+		Expect(actual).To(Equal(""))
+		Expect(err).ToNot(BeNil())
+	})
+	It("RemoveStopWords", func() {
+		actual, err := formatIssue(
+			"The Phase of the moon in Autumn: a review",
+			"{{.Fields.Summary | RemoveStopWords }}",
+		)
+
+		Expect(actual).To(Equal("Phase moon Autumn: review"))
+		Expect(err).To(BeNil())
+	})
+	It("RemoveStopWords in another language", func() {
+		actual, err := formatIssue(
+			"Die Bäume und der Wald",
+			"{{.Fields.Summary | RemoveStopWords \"de\" }}",
+		)
+
+		Expect(actual).To(Equal("Bäume Wald"))
+		Expect(err).To(BeNil())
+	})
+	It("RemoveStopWords in an unknown language", func() {
+		actual, err := formatIssue(
+			"Die Bäume und der Wald",
+			"{{.Fields.Summary | RemoveStopWords \"xx\" }}",
+		)
+
+		Expect(actual).To(Equal(""))
+		Expect(err).ToNot(BeNil())
+	})
+	It("RemoveStopWords with configured words", func() {
+		actual, err := formatIssueWithConfig(
+			"The Phase of the moon",
+			"{{.Fields.Summary | RemoveStopWords }}",
+			TemplateConfig{
+				StopWords: map[string][]string{"en": {"phase"}},
+			},
+		)
+
+		Expect(actual).To(Equal("The of the moon"))
+		Expect(err).To(BeNil())
+	})
+	It("Abbreviate", func() {
+		actual, err := formatIssue(
+			"Authentication fails in production (database)",
+			"{{.Fields.Summary | Abbreviate }}",
+		)
+
+		Expect(actual).To(Equal("Auth fails in prod (db)"))
+		Expect(err).To(BeNil())
+	})
+	It("Abbreviate with configured words", func() {
+		actual, err := formatIssueWithConfig(
+			"Improve KUBERNETES configuration",
+			"{{.Fields.Summary | Abbreviate }}",
+			TemplateConfig{
+				Abbreviations: map[string]string{"kubernetes": "k8s"},
+			},
+		)
+
+		Expect(actual).To(Equal("Improve K8S config"))
+		Expect(err).To(BeNil())
+	})
+	It("Abbreviate to nothing", func() {
+		actual, err := formatIssueWithConfig(
+			"Improve Kubernetes config",
+			"{{.Fields.Summary | Abbreviate }}",
+			TemplateConfig{
+				Abbreviations: map[string]string{"kubernetes": ""},
+			},
+		)
+
+		Expect(actual).To(Equal("Improve  config"))
+		Expect(err).To(BeNil())
+	})
+	It("FirstWords", func() {
+		actual, err := formatIssue(
+			"Developments Phase 1: Implement Feature γ Bäume",
+			"{{.Fields.Summary | FirstWords 3 }}",
+		)
+
+		Expect(actual).To(Equal("Developments Phase 1:"))
+		Expect(err).To(BeNil())
+	})
+	It("FirstWords with a negative number", func() {
+		actual, err := formatIssue(
+			"Developments Phase 1",
+			"{{.Fields.Summary | FirstWords -1 }}",
+		)
+
+		Expect(actual).To(Equal(""))
+		Expect(err).To(BeNil())
+	})
+	It("FirstWords with fewer words", func() {
+		actual, err := formatIssue(
+			"Developments",
+			"{{.Fields.Summary | FirstWords 3 }}",
+		)
+
+		Expect(actual).To(Equal("Developments"))
+		Expect(err).To(BeNil())
+	})
+	It("Default", func() {
+		actual, err := formatIssue(
+			"   ",
+			"{{.Fields.Summary | Default \"untitled\" }}",
+		)
+
+		Expect(actual).To(Equal("untitled"))
+		Expect(err).To(BeNil())
+	})
+	It("Default with a value", func() {
+		actual, err := formatIssue(
+			"Developments",
+			"{{.Fields.Summary | Default \"untitled\" }}",
+		)
+
+		Expect(actual).To(Equal("Developments"))
+		Expect(err).To(BeNil())
+	})
+	It("Split and Join", func() {
+		actual, err := formatIssue(
+			"Developments Phase 1",
+			"{{.Fields.Summary | Split \" \" | Join \"+\" }}",
+		)
+
+		Expect(actual).To(Equal("Developments+Phase+1"))
+		Expect(err).To(BeNil())
+	})
+	It("Join labels", func() {
+		subject := Jira{Client: testGetIssue{
+			issue: &jira.Issue{Fields: &jira.IssueFields{
+				Labels: []string{"frontend", "bug"},
+			}},
+		}}
+
+		actual, err := subject.FormatIssue(
+			"TST-123",
+			"{{.Fields.Labels | Join \"-\" }}",
+		)
+
+		Expect(actual).To(Equal("frontend-bug"))
+		Expect(err).To(BeNil())
+	})
+	It("Slug", func() {
+		actual, err := formatIssue(
+			"  Developments Phase 1: Implement Feature γ Bäume! ",
+			"{{.Fields.Summary | Slug }}",
+		)
+
+		Expect(actual).To(Equal("developments-phase-1-implement-feature-baume"))
+		Expect(err).To(BeNil())
+	})
+	It("Slug drops characters git dislikes", func() {
+		actual, err := formatIssue(
+			"Don't use ~^:?*[\\ or .. in refs/heads.lock",
+			"{{.Fields.Summary | Slug }}",
+		)
+
+		Expect(actual).To(Equal("dont-use-or-in-refs-heads-lock"))
+		Expect(err).To(BeNil())
+	})
+})
+
+func formatIssueWithConfig(
+	summary string,
+	templ string,
+	config TemplateConfig,
+) (string, error) {
+	subject := Jira{
+		Client: testGetIssue{
+			issue: &jira.Issue{Fields: &jira.IssueFields{
+				Summary: summary,
+			}},
+		},
+		Config: config,
+	}
+
+	return subject.FormatIssue("TST-123", templ)
+}
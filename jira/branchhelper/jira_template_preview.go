@@ -0,0 +1,165 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/pkg/errors"
+)
+
+// SampleSummaries are awkward issue summaries that templates should cope with,
+// by name
+var SampleSummaries = []SampleSummary{
+	{
+		Name:    "unicode",
+		Summary: "Développer la fonctionnalité γ für Bäume 🚀",
+	},
+	{
+		Name:    "punctuation",
+		Summary: "  [API] Don't crash on ~^:?*\\ in \"refs/heads/..\"!  ",
+	},
+	{
+		Name: "long",
+		Summary: "As a user I want to be able to export every report " +
+			"in the reporting section to CSV, PDF and Excel so that I " +
+			"can share them with people who do not have an account",
+	},
+}
+
+// SampleSummary is a named issue summary used to preview templates
+type SampleSummary struct {
+	Name    string
+	Summary string
+}
+
+// TemplatePreview is what a template rendered for an issue summary
+type TemplatePreview struct {
+	Name    string
+	Summary string
+	Branch  string
+	Err     error
+}
+
+// StaticIssueClient only has one issue, without talking to Jira
+type StaticIssueClient struct {
+	Issue *jira.Issue
+}
+
+// Get returns the static issue if it is the one asked for, and answers as
+// Jira would for an issue that doesn't exist otherwise
+func (c StaticIssueClient) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	if c.Issue != nil &&
+		(issueID == c.Issue.ID || strings.EqualFold(issueID, c.Issue.Key)) {
+		return c.Issue, nil, nil
+	}
+
+	resp := &jira.Response{
+		Response: &http.Response{StatusCode: http.StatusNotFound},
+	}
+
+	return nil, resp, errors.Errorf("issue %s isn't available without jira", issueID)
+}
+
+// SampleIssue an issue with the commonly used fields filled in, to check
+// templates against
+func SampleIssue() *jira.Issue {
+	user := &jira.User{
+		Name:         "jbloggs",
+		Key:          "jbloggs",
+		DisplayName:  "Joe Bloggs",
+		EmailAddress: "jbloggs@example.com",
+	}
+
+	return &jira.Issue{
+		ID:  "10000",
+		Key: "TST-123",
+		Fields: &jira.IssueFields{
+			Summary:     "Implement the login page",
+			Description: "Users need to be able to log in",
+			Type:        jira.IssueType{Name: "Story"},
+			Project:     jira.Project{Key: "TST", Name: "Test"},
+			Status:      &jira.Status{Name: "To Do"},
+			Priority:    &jira.Priority{Name: "Medium"},
+			Assignee:    user,
+			Reporter:    user,
+			Creator:     user,
+			Labels:      []string{"frontend"},
+		},
+	}
+}
+
+// PreviewTemplate render a template for an issue, then again for a copy of the
+// issue with each of the sample summaries. Nothing is fetched from Jira.
+// Template errors are reported on each preview
+func (helper *Jira) PreviewTemplate(
+	templ *template.Template,
+	issue *jira.Issue,
+	samples []SampleSummary,
+) []TemplatePreview {
+	previews := []TemplatePreview{
+		helper.preview(templ, "fixture", issue),
+	}
+
+	for _, sample := range samples {
+		sampleIssue := *issue
+		sampleFields := jira.IssueFields{}
+
+		if issue.Fields != nil {
+			sampleFields = *issue.Fields
+		}
+
+		sampleFields.Summary = sample.Summary
+		sampleIssue.Fields = &sampleFields
+
+		previews = append(
+			previews,
+			helper.preview(templ, sample.Name, &sampleIssue),
+		)
+	}
+
+	return previews
+}
+
+func (helper *Jira) preview(
+	templ *template.Template,
+	name string,
+	issue *jira.Issue,
+) TemplatePreview {
+	preview := TemplatePreview{Name: name}
+
+	if issue.Fields != nil {
+		preview.Summary = strings.TrimSpace(issue.Fields.Summary)
+	}
+
+	previewer := &Jira{
+		Client: StaticIssueClient{Issue: issue},
+		Fields: helper.Fields,
+		Config: helper.Config,
+	}
+	preview.Branch, preview.Err = previewer.NewRenderer(templ).Render(issue)
+
+	return preview
+}
@@ -0,0 +1,141 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"net/http"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PreviewTemplate", func() {
+	samples := []SampleSummary{
+		{Name: "unicode", Summary: "Bäume γ"},
+		{Name: "punctuation", Summary: "What?! No: way"},
+	}
+
+	It("Renders the fixture and each sample", func() {
+		subject := Jira{}
+		templ, err := subject.ParseTemplate(
+			"{{.Key | ToLower}}-{{.Fields.Summary | Slug}}",
+		)
+		Expect(err).To(BeNil())
+
+		actual := subject.PreviewTemplate(templ, SampleIssue(), samples)
+
+		Expect(actual).To(Equal([]TemplatePreview{
+			{
+				Name:    "fixture",
+				Summary: "Implement the login page",
+				Branch:  "tst-123-implement-the-login-page",
+			},
+			{
+				Name:    "unicode",
+				Summary: "Bäume γ",
+				Branch:  "tst-123-baume",
+			},
+			{
+				Name:    "punctuation",
+				Summary: "What?! No: way",
+				Branch:  "tst-123-what-no-way",
+			},
+		}))
+	})
+	It("Does not change the fixture", func() {
+		subject := Jira{}
+		templ, err := subject.ParseTemplate("{{.Fields.Summary}}")
+		Expect(err).To(BeNil())
+		fixture := SampleIssue()
+
+		subject.PreviewTemplate(templ, fixture, samples)
+
+		Expect(fixture.Fields.Summary).To(Equal("Implement the login page"))
+	})
+	It("Copes with fixtures without fields", func() {
+		subject := Jira{}
+		templ, err := subject.ParseTemplate("{{.Fields.Summary}}")
+		Expect(err).To(BeNil())
+
+		actual := subject.PreviewTemplate(
+			templ,
+			&jira.Issue{Key: "TST-1"},
+			samples,
+		)
+
+		Expect(actual[0].Err).ToNot(BeNil())
+		Expect(actual[1].Branch).To(Equal("Bäume γ"))
+	})
+	It("Reports related issues it doesn't have", func() {
+		subject := Jira{}
+		templ, err := subject.ParseTemplate("{{with .Epic}}{{.Key}}{{end}}")
+		Expect(err).To(BeNil())
+		fixture := SampleIssue()
+		fixture.Fields.Type.Name = "Sub-task"
+		fixture.Fields.Parent = &jira.Parent{ID: "10001", Key: "TST-1"}
+
+		actual := subject.PreviewTemplate(templ, fixture, samples)
+
+		for _, preview := range actual {
+			Expect(preview.Err).ToNot(BeNil())
+			Expect(preview.Err.Error()).To(ContainSubstring("TST-1"))
+		}
+	})
+	It("Reports unknown fields", func() {
+		subject := Jira{}
+		templ, err := subject.ParseTemplate("{{.Fields.Nope}}")
+		Expect(err).To(BeNil())
+
+		actual := subject.PreviewTemplate(templ, SampleIssue(), samples)
+
+		for _, preview := range actual {
+			Expect(preview.Err).ToNot(BeNil())
+			Expect(preview.Err.Error()).To(ContainSubstring("Nope"))
+		}
+	})
+})
+
+var _ = Describe("StaticIssueClient", func() {
+	It("Returns its issue by key or ID", func() {
+		issue := SampleIssue()
+		subject := StaticIssueClient{Issue: issue}
+
+		actual, resp, err := subject.Get("tst-123", nil)
+
+		Expect(actual).To(Equal(issue))
+		Expect(resp).To(BeNil())
+		Expect(err).To(BeNil())
+
+		actual, _, err = subject.Get("10000", nil)
+
+		Expect(actual).To(Equal(issue))
+		Expect(err).To(BeNil())
+	})
+	It("Doesn't find other issues", func() {
+		subject := StaticIssueClient{Issue: SampleIssue()}
+
+		actual, resp, err := subject.Get("OTHER-1", nil)
+
+		Expect(actual).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(err).ToNot(BeNil())
+	})
+})
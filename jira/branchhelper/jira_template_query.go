@@ -0,0 +1,341 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// minimalFields is requested when a template uses no fields at all, as Jira
+// returns every field when none are asked for
+const minimalFields = "summary"
+
+// issueExpansions are the parts of an issue that Jira only returns when asked
+// to expand them, by the name of the field on jira.Issue
+var issueExpansions = map[string]string{
+	"Names":          "names",
+	"RenderedFields": "renderedFields",
+	"Changelog":      "changelog",
+	"Transitions":    "transitions",
+}
+
+// issueAttributes are always returned, whatever fields are asked for
+var issueAttributes = map[string]bool{
+	"Key":    true,
+	"ID":     true,
+	"Self":   true,
+	"Expand": true,
+}
+
+// dotKind is what "." refers to at a point in a template
+type dotKind int
+
+const (
+	dotIssue dotKind = iota
+	dotOther
+	dotUnknown
+)
+
+// templateUsage is what parts of an issue a template uses
+type templateUsage struct {
+	dynamic    bool
+	fields     map[string]bool
+	expand     map[string]bool
+	fieldNames map[string]bool
+}
+
+func newTemplateUsage() *templateUsage {
+	return &templateUsage{
+		fields:     map[string]bool{},
+		expand:     map[string]bool{},
+		fieldNames: map[string]bool{},
+	}
+}
+
+// analyseTemplate work out which parts of the issue a template, and every
+// template it is associated with, uses
+func analyseTemplate(templ *template.Template) *templateUsage {
+	usage := newTemplateUsage()
+
+	for _, associated := range templ.Templates() {
+		if associated.Tree != nil {
+			usage.walk(associated.Tree.Root, dotIssue)
+		}
+	}
+
+	return usage
+}
+
+func (usage *templateUsage) walk(node parse.Node, dot dotKind) {
+	switch typed := node.(type) {
+	case *parse.ListNode:
+		if typed == nil {
+			return
+		}
+
+		for _, child := range typed.Nodes {
+			usage.walk(child, dot)
+		}
+	case *parse.ActionNode:
+		usage.pipe(typed.Pipe, dot)
+	case *parse.TemplateNode:
+		if isDotPipe(typed.Pipe) {
+			// Handing the issue to another template is fine, as every
+			// template is analysed
+			return
+		}
+
+		usage.pipe(typed.Pipe, dot)
+	case *parse.IfNode:
+		usage.branch(&typed.BranchNode, dot, dot)
+	case *parse.RangeNode:
+		usage.branch(&typed.BranchNode, dot, dotUnknown)
+	case *parse.WithNode:
+		usage.branch(&typed.BranchNode, dot, usage.pipeDot(typed.Pipe, dot))
+	}
+}
+
+func (usage *templateUsage) branch(
+	branch *parse.BranchNode,
+	dot dotKind,
+	innerDot dotKind,
+) {
+	usage.pipe(branch.Pipe, dot)
+	usage.walk(branch.List, innerDot)
+	usage.walk(branch.ElseList, dot)
+}
+
+// pipeDot what "." will be inside a with block for the pipeline
+func (usage *templateUsage) pipeDot(pipe *parse.PipeNode, dot dotKind) dotKind {
+	if dot != dotIssue || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return dotUnknown
+	}
+
+	field, ok := pipe.Cmds[0].Args[0].(*parse.FieldNode)
+	if !ok || len(field.Ident) != 1 {
+		return dotUnknown
+	}
+
+	switch field.Ident[0] {
+	case "Parent", "Epic":
+		return dotIssue
+	case "Sprint":
+		return dotOther
+	}
+
+	return dotUnknown
+}
+
+func isDotPipe(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) != 1 {
+		return false
+	}
+
+	_, ok := pipe.Cmds[0].Args[0].(*parse.DotNode)
+	return ok
+}
+
+func (usage *templateUsage) pipe(pipe *parse.PipeNode, dot dotKind) {
+	if pipe == nil {
+		return
+	}
+
+	for _, cmd := range pipe.Cmds {
+		usage.command(cmd, dot)
+	}
+}
+
+func (usage *templateUsage) command(cmd *parse.CommandNode, dot dotKind) {
+	for i, arg := range cmd.Args {
+		switch typed := arg.(type) {
+		case *parse.IdentifierNode:
+			// Only a name written in the template can be looked for. Piped
+			// in, or worked out, it could be any field
+			if typed.Ident != "Field" {
+				continue
+			}
+
+			if i == 0 && len(cmd.Args) > 1 {
+				if name, ok := cmd.Args[1].(*parse.StringNode); ok {
+					usage.fieldNames[name.Text] = true
+
+					continue
+				}
+			}
+
+			usage.dynamic = true
+		case *parse.FieldNode:
+			usage.identifiers(typed.Ident, dot)
+		case *parse.VariableNode:
+			if typed.Ident[0] == "$" {
+				usage.identifiers(typed.Ident[1:], dotIssue)
+			} else if len(typed.Ident) > 1 {
+				usage.dynamic = true
+			}
+		case *parse.DotNode:
+			// Anything given the whole issue could look at any part of it
+			if dot != dotOther {
+				usage.dynamic = true
+			}
+		case *parse.ChainNode:
+			usage.dynamic = true
+		case *parse.PipeNode:
+			usage.pipe(typed, dot)
+		}
+	}
+}
+
+// identifiers record the use of a chain of fields, e.g. .Fields.Summary
+func (usage *templateUsage) identifiers(ident []string, dot dotKind) {
+	switch dot {
+	case dotOther:
+		return
+	case dotUnknown:
+		usage.dynamic = true
+		return
+	}
+
+	if len(ident) == 0 {
+		usage.dynamic = true
+		return
+	}
+
+	if expansion, ok := issueExpansions[ident[0]]; ok {
+		usage.expand[expansion] = true
+		return
+	}
+
+	switch ident[0] {
+	case "Fields":
+		usage.issueField(ident[1:])
+	case "Parent":
+		usage.fields["parent"] = true
+	case "Epic":
+		usage.fields["epic"] = true
+		usage.fields["parent"] = true
+		usage.fieldNames[epicLinkField] = true
+	case "Sprint":
+		usage.fieldNames[sprintField] = true
+	case "Title":
+		usage.fields["summary"] = true
+	case "Type":
+		usage.fields["issuetype"] = true
+	case "Labels":
+		usage.fields["labels"] = true
+	case "URL":
+		// Built from the issue's self link, which is always returned
+	default:
+		if !issueAttributes[ident[0]] {
+			usage.dynamic = true
+		}
+	}
+}
+
+// issueField record the use of a field of jira.IssueFields, by its name in
+// the Jira API
+func (usage *templateUsage) issueField(ident []string) {
+	if len(ident) == 0 {
+		usage.dynamic = true
+		return
+	}
+
+	if ident[0] == "Unknowns" {
+		if len(ident) < 2 {
+			usage.dynamic = true
+			return
+		}
+
+		usage.fields[ident[1]] = true
+		return
+	}
+
+	field, ok := reflect.TypeOf(jira.IssueFields{}).FieldByName(ident[0])
+	if !ok {
+		usage.dynamic = true
+		return
+	}
+
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		usage.dynamic = true
+		return
+	}
+
+	usage.fields[name] = true
+}
+
+// queryOptions the smallest query that fetches everything a template uses. If
+// that can't be worked out, nil so that everything is fetched
+func (helper *Jira) queryOptions(
+	ctx context.Context,
+	templ *template.Template,
+) *jira.GetQueryOptions {
+	usage := analyseTemplate(templ)
+
+	if usage.dynamic {
+		return nil
+	}
+
+	if len(usage.fieldNames) > 0 {
+		if helper.Fields == nil {
+			// Without a fields client names are looked up from the issue
+			usage.expand["names"] = true
+			return &jira.GetQueryOptions{Expand: joinKeys(usage.expand)}
+		}
+
+		fields, err := getFields(ctx, helper.Fields)
+		if err != nil {
+			return nil
+		}
+
+		for name := range usage.fieldNames {
+			if field, ok := findField(fields, name); ok {
+				usage.fields[field.ID] = true
+			}
+		}
+	}
+
+	if len(usage.fields) == 0 {
+		usage.fields[minimalFields] = true
+	}
+
+	return &jira.GetQueryOptions{
+		Fields: joinKeys(usage.fields),
+		Expand: joinKeys(usage.expand),
+	}
+}
+
+func joinKeys(set map[string]bool) string {
+	keys := []string{}
+
+	for key := range set {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return strings.Join(keys, ",")
+}
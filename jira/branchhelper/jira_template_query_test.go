@@ -0,0 +1,177 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"errors"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetching only the fields templates use", func() {
+	queries := []struct {
+		name     string
+		templ    string
+		expected *jira.GetQueryOptions
+	}{
+		{
+			"the default template",
+			"{{.Key | ToLower }}-{{.Fields.Summary | Trim | KebabCase }}",
+			&jira.GetQueryOptions{Fields: "summary"},
+		},
+		{
+			"no fields",
+			"{{.Key}}",
+			&jira.GetQueryOptions{Fields: "summary"},
+		},
+		{
+			"fields with other names in the api",
+			"{{.Fields.Type.Name}}/{{.Fields.Labels | Join \"-\"}}",
+			&jira.GetQueryOptions{Fields: "issuetype,labels"},
+		},
+		{
+			"fields in conditions and pipelines",
+			"{{if .Fields.Assignee}}{{.Fields.Status.Name | Default .Fields.Summary}}{{end}}",
+			&jira.GetQueryOptions{Fields: "assignee,status,summary"},
+		},
+		{
+			"custom fields by id",
+			"{{.Fields.Unknowns.customfield_10011}}",
+			&jira.GetQueryOptions{Fields: "customfield_10011"},
+		},
+		{
+			"custom fields by name",
+			"{{Field \"Team\"}}-{{Field \"Missing\"}}",
+			&jira.GetQueryOptions{Fields: "customfield_10030"},
+		},
+		{
+			"the details any tracker has",
+			"{{.Type}}/{{.Key}}-{{.Title | KebabCase}}-{{.Labels | Join \"-\"}} {{.URL}}",
+			&jira.GetQueryOptions{Fields: "issuetype,labels,summary"},
+		},
+		{
+			"expansions",
+			"{{.Names}}",
+			&jira.GetQueryOptions{Fields: "summary", Expand: "names"},
+		},
+		{
+			"the parent",
+			"{{with .Parent}}{{.Key}}/{{end}}{{.Key}}",
+			&jira.GetQueryOptions{Fields: "parent"},
+		},
+		{
+			"the epic",
+			"{{.Epic.Key}}",
+			&jira.GetQueryOptions{Fields: "customfield_10014,epic,parent"},
+		},
+		{
+			"the sprint",
+			"{{with .Sprint}}{{.Name}}{{end}}",
+			&jira.GetQueryOptions{Fields: "customfield_10020"},
+		},
+		{
+			"variables from the root",
+			"{{range .Fields.Labels}}{{$.Fields.Summary}}{{end}}",
+			&jira.GetQueryOptions{Fields: "labels,summary"},
+		},
+		{"the whole issue", "{{.}}", nil},
+		{"the issue in a function", "{{. | printf \"%v\"}}", nil},
+		{"the fields in a with block", "{{with .Fields}}{{.Summary}}{{end}}", nil},
+		{"fields in a range block", "{{range .Fields.Components}}{{.Name}}{{end}}", nil},
+		{"every custom field", "{{index .Fields.Unknowns \"customfield_1\"}}", nil},
+		{"fields by a variable", "{{$f := .Fields}}{{$f.Summary}}", nil},
+		{"field names from a variable", "{{$n := \"Team\"}}{{Field $n}}", nil},
+		{"field names piped in", "{{\"Team\" | Field}}", nil},
+		{"field names from the issue", "{{.Fields.Summary | Field | ToLower}}", nil},
+		{"unknown fields", "{{.Fields.Nope}}", nil},
+	}
+
+	for _, query := range queries {
+		query := query
+
+		It("Works out the query for "+query.name, func() {
+			client := &testQueryRecorder{}
+			subject := Jira{
+				Client: client,
+				Fields: &testGetFields{fields: []FieldMetadata{
+					{ID: "customfield_10014", Name: "Epic Link", Custom: true},
+					{ID: "customfield_10020", Name: "Sprint", Custom: true},
+					{ID: "customfield_10030", Name: "Team", Custom: true},
+				}},
+			}
+
+			_, _ = subject.FormatIssue("TST-123", query.templ)
+
+			Expect(client.options).To(Equal(query.expected))
+		})
+	}
+
+	It("Includes templates from partials", func() {
+		client := &testQueryRecorder{}
+		subject := Jira{Client: client}
+		templ, err := subject.ParseTemplate(
+			"{{define \"prefix\"}}{{.Fields.Type.Name}}{{end}}" +
+				"{{template \"prefix\" .}}/{{.Fields.Summary}}",
+		)
+		Expect(err).To(BeNil())
+
+		_, _ = subject.FormatIssueTemplate("TST-123", templ)
+
+		Expect(client.options).To(Equal(
+			&jira.GetQueryOptions{Fields: "issuetype,summary"},
+		))
+	})
+	It("Expands names without a fields client", func() {
+		client := &testQueryRecorder{}
+		subject := Jira{Client: client}
+
+		_, _ = subject.FormatIssue("TST-123", "{{Field \"Team\"}}")
+
+		Expect(client.options).To(Equal(
+			&jira.GetQueryOptions{Expand: "names"},
+		))
+	})
+	It("Fetches everything when the fields can't be listed", func() {
+		client := &testQueryRecorder{}
+		subject := Jira{
+			Client: client,
+			Fields: &testGetFields{err: errors.New("broken")},
+		}
+
+		_, _ = subject.FormatIssue("TST-123", "{{Field \"Team\"}}")
+
+		Expect(client.options).To(BeNil())
+	})
+})
+
+type testQueryRecorder struct {
+	options *jira.GetQueryOptions
+}
+
+func (t *testQueryRecorder) Get(
+	issueID string,
+	options *jira.GetQueryOptions,
+) (*jira.Issue, *jira.Response, error) {
+	t.options = options
+
+	return &jira.Issue{Key: issueID, Fields: &jira.IssueFields{}}, nil, nil
+}
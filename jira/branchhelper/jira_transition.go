@@ -0,0 +1,152 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Transition is a move an issue can make through its workflow
+type Transition struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	To   struct {
+		Name string `json:"name"`
+	} `json:"to"`
+}
+
+// String describe the transition, for listing to users
+func (t Transition) String() string {
+	if t.To.Name == "" || t.To.Name == t.Name {
+		return fmt.Sprintf("%q (%s)", t.Name, t.ID)
+	}
+
+	return fmt.Sprintf("%q (%s) to %q", t.Name, t.ID, t.To.Name)
+}
+
+// TransitionsClient moves issues through their workflow with the Jira API
+type TransitionsClient struct {
+	Client     RequestClient
+	APIVersion string
+}
+
+// GetTransitions lists the transitions available for an issue
+func (c TransitionsClient) GetTransitions(issueID string) ([]Transition, error) {
+	req, err := c.Client.NewRequest(
+		"GET",
+		issuePath(c.APIVersion, issueID, "/transitions"),
+		nil,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build transitions request")
+	}
+
+	result := struct {
+		Transitions []Transition `json:"transitions"`
+	}{}
+	resp, err := c.Client.Do(req, &result)
+
+	if err != nil {
+		return nil, newRequestError(err, resp)
+	}
+
+	return result.Transitions, nil
+}
+
+// DoTransition perform a transition on an issue
+func (c TransitionsClient) DoTransition(issueID string, transitionID string) error {
+	body := map[string]interface{}{
+		"transition": map[string]string{"id": transitionID},
+	}
+	req, err := c.Client.NewRequest(
+		"POST",
+		issuePath(c.APIVersion, issueID, "/transitions"),
+		body,
+	)
+	if err != nil {
+		return errors.Wrap(err, "failed to build transition request")
+	}
+
+	if resp, err := c.Client.Do(req, nil); err != nil {
+		return newRequestError(err, resp)
+	}
+
+	return nil
+}
+
+// TransitionIssue find the transition matching the name or ID, and perform it
+// unless this is a dry run. Returns the transition found
+func (c TransitionsClient) TransitionIssue(
+	issueID string,
+	nameOrID string,
+	dryRun bool,
+) (Transition, error) {
+	transitions, err := c.GetTransitions(issueID)
+	if err != nil {
+		return Transition{}, err
+	}
+
+	transition, err := FindTransition(transitions, nameOrID)
+	if err != nil || dryRun {
+		return transition, err
+	}
+
+	return transition, c.DoTransition(issueID, transition.ID)
+}
+
+// FindTransition the transition with the ID, or the name (ignoring case).
+// The name of the status the transition leads to is also accepted
+func FindTransition(
+	transitions []Transition,
+	nameOrID string,
+) (Transition, error) {
+	for _, transition := range transitions {
+		if transition.ID == nameOrID ||
+			strings.EqualFold(transition.Name, nameOrID) {
+			return transition, nil
+		}
+	}
+
+	for _, transition := range transitions {
+		if strings.EqualFold(transition.To.Name, nameOrID) {
+			return transition, nil
+		}
+	}
+
+	if len(transitions) == 0 {
+		return Transition{}, errors.Errorf(
+			"no transition %q, the issue has no transitions available",
+			nameOrID,
+		)
+	}
+
+	valid := []string{}
+	for _, transition := range transitions {
+		valid = append(valid, transition.String())
+	}
+
+	return Transition{}, errors.Errorf(
+		"no transition %q, valid transitions are %s",
+		nameOrID,
+		strings.Join(valid, ", "),
+	)
+}
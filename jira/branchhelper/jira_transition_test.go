@@ -0,0 +1,132 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/json"
+	"net/http"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testTransitionsJSON = `{"transitions": [
+	{"id": "11", "name": "To Do", "to": {"name": "To Do"}},
+	{"id": "21", "name": "Start Progress", "to": {"name": "In Progress"}},
+	{"id": "31", "name": "Done", "to": {"name": "Done"}}
+]}`
+
+var _ = Describe("TransitionsClient", func() {
+	var performed []string
+	var subject TransitionsClient
+	var stop func()
+
+	BeforeEach(func() {
+		performed = []string{}
+		client, closeServer := testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).To(Equal("/rest/api/2/issue/TST-123/transitions"))
+
+			if r.Method == "POST" {
+				body := struct {
+					Transition struct {
+						ID string `json:"id"`
+					} `json:"transition"`
+				}{}
+				Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+				performed = append(performed, body.Transition.ID)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(testTransitionsJSON))
+		})
+		subject = TransitionsClient{Client: client}
+		stop = closeServer
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("Lists transitions", func() {
+		actual, err := subject.GetTransitions("TST-123")
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(HaveLen(3))
+		Expect(actual[1].Name).To(Equal("Start Progress"))
+		Expect(actual[1].To.Name).To(Equal("In Progress"))
+	})
+	It("Transitions by name", func() {
+		actual, err := subject.TransitionIssue("TST-123", "start progress", false)
+
+		Expect(err).To(BeNil())
+		Expect(actual.ID).To(Equal("21"))
+		Expect(performed).To(Equal([]string{"21"}))
+	})
+	It("Transitions by status", func() {
+		actual, err := subject.TransitionIssue("TST-123", "In Progress", false)
+
+		Expect(err).To(BeNil())
+		Expect(actual.ID).To(Equal("21"))
+		Expect(performed).To(Equal([]string{"21"}))
+	})
+	It("Transitions by id", func() {
+		actual, err := subject.TransitionIssue("TST-123", "31", false)
+
+		Expect(err).To(BeNil())
+		Expect(actual.Name).To(Equal("Done"))
+		Expect(performed).To(Equal([]string{"31"}))
+	})
+	It("Does nothing on a dry run", func() {
+		actual, err := subject.TransitionIssue("TST-123", "In Progress", true)
+
+		Expect(err).To(BeNil())
+		Expect(actual.ID).To(Equal("21"))
+		Expect(performed).To(BeEmpty())
+	})
+	It("Lists the valid transitions when none match", func() {
+		_, err := subject.TransitionIssue("TST-123", "In Review", false)
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring(
+			`"To Do" (11), "Start Progress" (21) to "In Progress", "Done" (31)`,
+		))
+		Expect(performed).To(BeEmpty())
+	})
+})
+
+var _ = Describe("FindTransition", func() {
+	It("Explains when there are no transitions", func() {
+		_, err := FindTransition([]Transition{}, "Done")
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(ContainSubstring("no transitions available"))
+	})
+	It("Prefers transition names to statuses", func() {
+		transitions := []Transition{{ID: "1", Name: "Reopen"}, {ID: "2", Name: "Done"}}
+		transitions[0].To.Name = "Done"
+
+		actual, err := FindTransition(transitions, "Done")
+
+		Expect(err).To(BeNil())
+		Expect(actual.ID).To(Equal("2"))
+	})
+})
@@ -0,0 +1,208 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxWebhookSize is the largest webhook payload read, issues with long
+// descriptions and many comments can be large
+const maxWebhookSize = 10 << 20
+
+// webhookSignatureHeader is the header Jira signs webhooks with, when they
+// have a secret, e.g. "sha256=abc123"
+const webhookSignatureHeader = "X-Hub-Signature"
+
+// The webhook events branch names are built for
+const (
+	webhookIssueCreated = "jira:issue_created"
+	webhookIssueUpdated = "jira:issue_updated"
+)
+
+// commentEvents are the kinds of update that are only to comments, which
+// includes the comments we make, so they are ignored
+var commentEvents = map[string]bool{
+	"issue_commented":       true,
+	"issue_comment_edited":  true,
+	"issue_comment_deleted": true,
+}
+
+// CommentBranchFunc comments the branch name on an issue, returning if a
+// comment was needed
+type CommentBranchFunc func(
+	ctx context.Context,
+	issueID string,
+	branchName string,
+) (bool, error)
+
+// webhookPayload is the part of a Jira webhook we use
+type webhookPayload struct {
+	WebhookEvent       string          `json:"webhookEvent"`
+	IssueEventTypeName string          `json:"issue_event_type_name"`
+	Issue              json.RawMessage `json:"issue"`
+}
+
+// webhookResponse is the answer to a webhook
+type webhookResponse struct {
+	Key       string `json:"key,omitempty"`
+	Branch    string `json:"branch,omitempty"`
+	Commented bool   `json:"commented"`
+	Status    string `json:"status,omitempty"`
+}
+
+func (s *BranchServer) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.WebhookSecret == "" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, statusResponse{
+			Error: "method not allowed",
+		})
+
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, statusResponse{
+			Error: "failed to read webhook",
+		})
+
+		return
+	}
+
+	if !verifyWebhook(r, body, s.WebhookSecret) {
+		writeJSON(w, http.StatusUnauthorized, statusResponse{
+			Error: "webhook secret doesn't match",
+		})
+
+		return
+	}
+
+	payload := webhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, statusResponse{
+			Error: "failed to read webhook: " + err.Error(),
+		})
+
+		return
+	}
+
+	if !wantsBranch(payload) {
+		writeJSON(w, http.StatusOK, webhookResponse{Status: "ignored"})
+
+		return
+	}
+
+	issue, err := decodeIssue(payload.Issue)
+	if err != nil || issue.Key == "" {
+		s.Metrics.ObserveRender(renderSourceWebhook, RenderBadRequest, 0)
+		writeJSON(w, http.StatusBadRequest, statusResponse{
+			Error: "webhook has no issue",
+		})
+
+		return
+	}
+
+	ctx := r.Context()
+	start := time.Now()
+	branch, err := s.renderer.RenderContext(ctx, issue)
+	duration := time.Since(start)
+
+	if err != nil {
+		outcome := RenderTemplateError
+		if failedRequest(ctx, err) {
+			outcome = RenderJiraError
+		}
+
+		s.Metrics.ObserveRender(renderSourceWebhook, outcome, duration)
+		s.logf("failed to build branch name for %s: %v", issue.Key, requestCause(err))
+		writeJSON(w, http.StatusUnprocessableEntity, statusResponse{
+			Error: "failed to build branch name",
+		})
+
+		return
+	}
+
+	s.Metrics.ObserveRender(renderSourceWebhook, RenderSuccess, duration)
+	response := webhookResponse{Key: issue.Key, Branch: branch}
+
+	if s.CommentBranch != nil {
+		response.Commented, err = s.CommentBranch(ctx, issue.Key, branch)
+
+		if err != nil {
+			s.logf("failed to comment on %s: %v", issue.Key, requestCause(err))
+			writeJSON(w, http.StatusBadGateway, statusResponse{
+				Error: "failed to comment on issue",
+			})
+
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// wantsBranch if a webhook is for an issue being created or changed, but not
+// only its comments
+func wantsBranch(payload webhookPayload) bool {
+	switch payload.WebhookEvent {
+	case webhookIssueCreated:
+		return true
+	case webhookIssueUpdated:
+		return !commentEvents[payload.IssueEventTypeName]
+	}
+
+	return false
+}
+
+// verifyWebhook check a webhook came from Jira. Webhooks with a secret are
+// signed, and webhooks that can't be signed carry the secret in the URL,
+// e.g. /webhook?secret=abc123
+func verifyWebhook(r *http.Request, body []byte, secret string) bool {
+	if signature := r.Header.Get(webhookSignatureHeader); signature != "" {
+		given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		_, _ = mac.Write(body)
+
+		return hmac.Equal(given, mac.Sum(nil))
+	}
+
+	given := r.URL.Query().Get("secret")
+
+	return subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1
+}
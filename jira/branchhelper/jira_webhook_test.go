@@ -0,0 +1,258 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const testWebhookJSON = `{
+	"webhookEvent": "jira:issue_created",
+	"issue_event_type_name": "issue_created",
+	"issue": {
+		"key": "TST-123",
+		"fields": {"summary": "Implement the login page"}
+	}
+}`
+
+func signWebhook(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(body))
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ = Describe("BranchServer webhooks", func() {
+	var calls int
+	var subject *BranchServer
+
+	BeforeEach(func() {
+		calls = 0
+		helper := &Jira{Client: countingGetIssue{calls: &calls}}
+		templ, err := helper.ParseTemplate(
+			"{{.Key | ToLower}}-{{.Fields.Summary | KebabCase}}",
+		)
+		Expect(err).To(BeNil())
+
+		subject = helper.NewBranchServer(templ)
+		subject.WebhookSecret = "secret"
+		subject.ErrorLog = log.New(ioutil.Discard, "", 0)
+	})
+
+	send := func(
+		method string,
+		target string,
+		body string,
+		signature string,
+	) (int, map[string]interface{}) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if signature != "" {
+			request.Header.Set("X-Hub-Signature", signature)
+		}
+
+		recorder := httptest.NewRecorder()
+		subject.ServeHTTP(recorder, request)
+
+		response := map[string]interface{}{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+
+		return recorder.Code, response
+	}
+
+	It("Builds branch names from signed webhooks", func() {
+		status, body := send(
+			"POST",
+			"/webhook",
+			testWebhookJSON,
+			signWebhook("secret", testWebhookJSON),
+		)
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal(map[string]interface{}{
+			"key":       "TST-123",
+			"branch":    "tst-123-implement-the-login-page",
+			"commented": false,
+		}))
+		Expect(calls).To(Equal(0))
+	})
+	It("Builds branch names from webhooks with the secret in the URL", func() {
+		status, body := send("POST", "/webhook?secret=secret", testWebhookJSON, "")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body["branch"]).To(Equal("tst-123-implement-the-login-page"))
+	})
+	It("Rejects webhooks signed with another secret", func() {
+		status, _ := send(
+			"POST",
+			"/webhook?secret=secret",
+			testWebhookJSON,
+			signWebhook("other", testWebhookJSON),
+		)
+
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+	It("Rejects webhooks without the secret", func() {
+		status, _ := send("POST", "/webhook?secret=other", testWebhookJSON, "")
+
+		Expect(status).To(Equal(http.StatusUnauthorized))
+	})
+	It("Doesn't accept webhooks without a secret to check", func() {
+		subject.WebhookSecret = ""
+
+		status, _ := send("POST", "/webhook?secret=", testWebhookJSON, "")
+
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+	It("Only accepts POST requests", func() {
+		status, _ := send("GET", "/webhook?secret=secret", "", "")
+
+		Expect(status).To(Equal(http.StatusMethodNotAllowed))
+	})
+	It("Rejects webhooks it can't read", func() {
+		status, _ := send("POST", "/webhook?secret=secret", "{", "")
+
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+	It("Builds branch names for updated issues", func() {
+		payload := `{
+			"webhookEvent": "jira:issue_updated",
+			"issue_event_type_name": "issue_generic",
+			"issue": {"key": "TST-123", "fields": {"summary": "Login"}}
+		}`
+
+		status, body := send("POST", "/webhook?secret=secret", payload, "")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body["branch"]).To(Equal("tst-123-login"))
+	})
+	It("Ignores comments", func() {
+		payload := `{
+			"webhookEvent": "jira:issue_updated",
+			"issue_event_type_name": "issue_commented",
+			"issue": {"key": "TST-123", "fields": {"summary": "Login"}}
+		}`
+
+		status, body := send("POST", "/webhook?secret=secret", payload, "")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body["status"]).To(Equal("ignored"))
+	})
+	It("Ignores other events", func() {
+		payload := `{"webhookEvent": "jira:issue_deleted"}`
+
+		status, body := send("POST", "/webhook?secret=secret", payload, "")
+
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body["status"]).To(Equal("ignored"))
+	})
+	Context("When Jira fails while building the branch name", func() {
+		var logged *bytes.Buffer
+		var stop func()
+
+		BeforeEach(func() {
+			server := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusInternalServerError)
+				},
+			))
+			stop = server.Close
+
+			client, err := jira.NewClient(
+				(&jira.BasicAuthTransport{Username: "user", Password: "hunter2"}).Client(),
+				server.URL+"/",
+			)
+			Expect(err).To(BeNil())
+
+			helper := NewJira(client)
+			templ, err := helper.ParseTemplate(`{{Field "Team"}}`)
+			Expect(err).To(BeNil())
+
+			logged = &bytes.Buffer{}
+			subject = helper.NewBranchServer(templ)
+			subject.WebhookSecret = "secret"
+			subject.ErrorLog = log.New(logged, "", 0)
+		})
+
+		AfterEach(func() {
+			stop()
+		})
+
+		It("Logs only what went wrong", func() {
+			status, _ := send("POST", "/webhook?secret=secret", testWebhookJSON, "")
+
+			Expect(status).To(Equal(http.StatusUnprocessableEntity))
+			Expect(logged.String()).To(ContainSubstring(
+				"failed to build branch name for TST-123",
+			))
+			Expect(logged.String()).ToNot(ContainSubstring("Authorization"))
+			Expect(logged.String()).ToNot(ContainSubstring("/rest/api/2/field"))
+		})
+	})
+	Context("When commenting", func() {
+		var commented []string
+		var commentErr error
+
+		BeforeEach(func() {
+			commented = []string{}
+			commentErr = nil
+			subject.CommentBranch = func(
+				ctx context.Context,
+				issueID string,
+				branchName string,
+			) (bool, error) {
+				commented = append(commented, issueID+" "+branchName)
+
+				return commentErr == nil, commentErr
+			}
+		})
+
+		It("Comments the branch name", func() {
+			status, body := send("POST", "/webhook?secret=secret", testWebhookJSON, "")
+
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body["commented"]).To(BeTrue())
+			Expect(commented).To(Equal([]string{
+				"TST-123 tst-123-implement-the-login-page",
+			}))
+		})
+		It("Reports failing to comment", func() {
+			commentErr = errors.New("forbidden")
+
+			status, _ := send("POST", "/webhook?secret=secret", testWebhookJSON, "")
+
+			Expect(status).To(Equal(http.StatusBadGateway))
+		})
+	})
+})
@@ -0,0 +1,215 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The kinds of issue tracker issues can come from
+const (
+	TrackerJira   = "jira"
+	TrackerGitHub = "github"
+	TrackerGitLab = "gitlab"
+	TrackerLinear = "linear"
+)
+
+// Issue is an issue from any tracker, with the details branch names are
+// usually built from, e.g. {{.Key}}-{{.Title | KebabCase}}
+type Issue struct {
+	Key    string
+	Title  string
+	Type   string
+	Labels []string
+	URL    string
+}
+
+// HTTPDoer makes HTTP requests, as a *http.Client does
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Tracker gets issues from an issue tracker
+type Tracker interface {
+	// IssueStrategy turns what the user gave us into a reference to an issue
+	IssueStrategy() IssueStrategy
+	// GetIssue get an issue by the reference the issue strategy gave
+	GetIssue(ctx context.Context, reference string) (*Issue, error)
+}
+
+// trackerStrategies are how issue URLs are recognised for each tracker that
+// isn't Jira
+var trackerStrategies = []struct {
+	kind     string
+	strategy ApplicableIssueStrategy
+}{
+	{kind: TrackerGitHub, strategy: GitHubIssueStrategy{}},
+	{kind: TrackerGitLab, strategy: GitLabIssueStrategy{}},
+	{kind: TrackerLinear, strategy: LinearIssueStrategy{}},
+}
+
+// DetectTracker the kind of tracker an issue URL is from, Jira if it isn't
+// one of the others
+func DetectTracker(issueURL *url.URL) string {
+	if issueURL != nil {
+		for _, tracker := range trackerStrategies {
+			if tracker.strategy.Applicable(issueURL) {
+				return tracker.kind
+			}
+		}
+	}
+
+	return TrackerJira
+}
+
+// NewTracker build a tracker of a kind. The endpoint is the root of its API,
+// the public service is used if it is empty. Jira trackers are built from a
+// Jira, see JiraTracker
+func NewTracker(
+	kind string,
+	client HTTPDoer,
+	endpoint string,
+	token string,
+) (Tracker, error) {
+	switch kind {
+	case TrackerGitHub:
+		return GitHubTracker{Client: client, Endpoint: endpoint, Token: token}, nil
+	case TrackerGitLab:
+		return GitLabTracker{Client: client, Endpoint: endpoint, Token: token}, nil
+	case TrackerLinear:
+		return LinearTracker{Client: client, Endpoint: endpoint, Token: token}, nil
+	}
+
+	return nil, errors.Errorf(
+		"unknown tracker %q, use %q, %q, %q or %q",
+		kind,
+		TrackerJira,
+		TrackerGitHub,
+		TrackerGitLab,
+		TrackerLinear,
+	)
+}
+
+// trackerRequest make a JSON request to a tracker API, decoding the response
+// into v
+func trackerRequest(
+	ctx context.Context,
+	client HTTPDoer,
+	method string,
+	requestURL string,
+	headers map[string]string,
+	body interface{},
+	v interface{},
+) error {
+	var reader io.Reader
+
+	if body != nil {
+		rawBody, err := json.Marshal(body)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode request")
+		}
+
+		reader = strings.NewReader(string(rawBody))
+	}
+
+	req, err := http.NewRequest(method, requestURL, reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to build request")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+		return errors.Errorf(
+			"%s %s: %s %s",
+			method,
+			requestURL,
+			resp.Status,
+			strings.TrimSpace(string(message)),
+		)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return errors.Wrap(err, "failed to decode response")
+	}
+
+	return nil
+}
+
+// apiURL join a path onto the root of an API, using the default root if none
+// is given
+func apiURL(endpoint string, defaultEndpoint string, path string) string {
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	return strings.TrimRight(endpoint, "/") + "/" + path
+}
+
+// repositoryIssue splits a reference like "org/repo#123"
+func repositoryIssue(reference string) (string, string, error) {
+	hash := strings.LastIndex(reference, "#")
+	if hash <= 0 || hash == len(reference)-1 {
+		return "", "", errors.Errorf(
+			"%q isn't an issue reference like \"org/repo#123\"",
+			reference,
+		)
+	}
+
+	return reference[:hash], reference[hash+1:], nil
+}
+
+// bearer the Authorization header for a token, if there is one
+func bearer(token string) map[string]string {
+	if token == "" {
+		return map[string]string{}
+	}
+
+	return map[string]string{"Authorization": fmt.Sprintf("Bearer %s", token)}
+}
@@ -0,0 +1,137 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const gitHubAPI = "https://api.github.com/"
+
+// gitHubPathReg matches the path of issues and pull requests on GitHub, e.g.
+// "/org/repo/issues/123"
+var gitHubPathReg = regexp.MustCompile(`^/([^/]+)/([^/]+)/(?:issues|pull)/(\d+)`)
+
+// repositoryIssueReg matches issue references like "org/repo#123"
+var repositoryIssueReg = regexp.MustCompile(`^[\w.-]+(?:/[\w.-]+)+#\d+$`)
+
+// GitHubIssueStrategy takes a GitHub issue URL, or a reference like
+// "org/repo#123", and turns it into a reference
+type GitHubIssueStrategy struct {
+}
+
+// Applicable the GitHub strategy handles issues on hosts like github.com
+func (s GitHubIssueStrategy) Applicable(issueURL *url.URL) bool {
+	return issueURL != nil &&
+		strings.Contains(issueURL.Host, "github") &&
+		gitHubPathReg.MatchString(issueURL.Path)
+}
+
+// GetIssue extracts a reference like "org/repo#123" from the issue
+func (s GitHubIssueStrategy) GetIssue(rawIssue string) (string, error) {
+	if repositoryIssueReg.MatchString(rawIssue) {
+		return rawIssue, nil
+	}
+
+	issueURL, err := url.Parse(rawIssue)
+	if err != nil {
+		return "", errors.Wrap(err, "issue url invalid")
+	}
+
+	matches := gitHubPathReg.FindStringSubmatch(issueURL.Path)
+	if matches == nil {
+		return "", errors.Errorf("%q isn't a GitHub issue", rawIssue)
+	}
+
+	return matches[1] + "/" + matches[2] + "#" + matches[3], nil
+}
+
+// GitHubTracker gets issues from GitHub, or GitHub Enterprise if the endpoint
+// is set
+type GitHubTracker struct {
+	Client   HTTPDoer
+	Endpoint string
+	Token    string
+}
+
+// IssueStrategy GitHub issues are referenced like "org/repo#123"
+func (t GitHubTracker) IssueStrategy() IssueStrategy {
+	return GitHubIssueStrategy{}
+}
+
+// GetIssue get an issue by a reference like "org/repo#123"
+func (t GitHubTracker) GetIssue(
+	ctx context.Context,
+	reference string,
+) (*Issue, error) {
+	repository, number, err := repositoryIssue(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Number  int    `json:"number"`
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		Labels  []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+		Type *struct {
+			Name string `json:"name"`
+		} `json:"type"`
+	}{}
+
+	headers := bearer(t.Token)
+	headers["Accept"] = "application/vnd.github+json"
+
+	if err := trackerRequest(
+		ctx,
+		t.Client,
+		"GET",
+		apiURL(t.Endpoint, gitHubAPI, "repos/"+repository+"/issues/"+number),
+		headers,
+		nil,
+		&result,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to get issue from github")
+	}
+
+	issue := &Issue{
+		Key:    strconv.Itoa(result.Number),
+		Title:  result.Title,
+		URL:    result.HTMLURL,
+		Labels: []string{},
+	}
+
+	if result.Type != nil {
+		issue.Type = result.Type.Name
+	}
+
+	for _, label := range result.Labels {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+
+	return issue, nil
+}
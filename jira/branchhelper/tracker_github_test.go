@@ -0,0 +1,122 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHubIssueStrategy", func() {
+	references := map[string]string{
+		"https://github.com/org/repo/issues/12":         "org/repo#12",
+		"https://github.com/org/repo/pull/12/files":     "org/repo#12",
+		"https://github.example.com/org/repo/issues/12": "org/repo#12",
+		"org/repo#12": "org/repo#12",
+	}
+
+	for raw, expected := range references {
+		raw, expected := raw, expected
+
+		It("Reads "+raw, func() {
+			actual, err := GitHubIssueStrategy{}.GetIssue(raw)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(expected))
+		})
+	}
+
+	It("Fails for other issues", func() {
+		_, err := GitHubIssueStrategy{}.GetIssue("TST-12")
+
+		Expect(err).ToNot(BeNil())
+	})
+})
+
+var _ = Describe("GitHubTracker", func() {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	var response string
+	var server *httptest.Server
+	var subject GitHubTracker
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		bodies = []map[string]interface{}{}
+		response = `{
+			"number": 12,
+			"title": "Login page",
+			"html_url": "https://github.com/org/repo/issues/12",
+			"labels": [{"name": "frontend"}, {"name": "auth"}],
+			"type": {"name": "Bug"}
+		}`
+	})
+
+	JustBeforeEach(func() {
+		server = testTrackerServer(response, &requests, &bodies)
+		subject = GitHubTracker{
+			Client:   http.DefaultClient,
+			Endpoint: server.URL + "/api/v3/",
+			Token:    "secret",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Gets issues", func() {
+		actual, err := subject.GetIssue(context.Background(), "org/repo#12")
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal(&Issue{
+			Key:    "12",
+			Title:  "Login page",
+			Type:   "Bug",
+			Labels: []string{"frontend", "auth"},
+			URL:    "https://github.com/org/repo/issues/12",
+		}))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.Path).To(Equal("/api/v3/repos/org/repo/issues/12"))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("Bearer secret"))
+	})
+	Context("When the issue doesn't exist", func() {
+		BeforeEach(func() {
+			response = ""
+		})
+
+		It("Fails", func() {
+			_, err := subject.GetIssue(context.Background(), "org/repo#12")
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("404"))
+		})
+	})
+	It("Needs a repository", func() {
+		_, err := subject.GetIssue(context.Background(), "12")
+
+		Expect(err).ToNot(BeNil())
+		Expect(requests).To(BeEmpty())
+	})
+})
@@ -0,0 +1,134 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const gitLabAPI = "https://gitlab.com/api/v4/"
+
+// gitLabPathReg matches the path of issues on GitLab, with or without the
+// "/-/" separator newer versions use e.g. "/group/repo/-/issues/123"
+var gitLabPathReg = regexp.MustCompile(`^/(.+?)(?:/-)?/(?:issues|work_items)/(\d+)`)
+
+// GitLabIssueStrategy takes a GitLab issue URL, or a reference like
+// "group/repo#123", and turns it into a reference
+type GitLabIssueStrategy struct {
+}
+
+// Applicable the GitLab strategy handles issues on hosts like gitlab.com
+func (s GitLabIssueStrategy) Applicable(issueURL *url.URL) bool {
+	return issueURL != nil &&
+		strings.Contains(issueURL.Host, "gitlab") &&
+		gitLabPathReg.MatchString(issueURL.Path)
+}
+
+// GetIssue extracts a reference like "group/repo#123" from the issue
+func (s GitLabIssueStrategy) GetIssue(rawIssue string) (string, error) {
+	if repositoryIssueReg.MatchString(rawIssue) {
+		return rawIssue, nil
+	}
+
+	issueURL, err := url.Parse(rawIssue)
+	if err != nil {
+		return "", errors.Wrap(err, "issue url invalid")
+	}
+
+	matches := gitLabPathReg.FindStringSubmatch(issueURL.Path)
+	if matches == nil {
+		return "", errors.Errorf("%q isn't a GitLab issue", rawIssue)
+	}
+
+	return matches[1] + "#" + matches[2], nil
+}
+
+// GitLabTracker gets issues from GitLab, or a self-managed GitLab if the
+// endpoint is set, e.g. "https://gitlab.example.com/api/v4/"
+type GitLabTracker struct {
+	Client   HTTPDoer
+	Endpoint string
+	Token    string
+}
+
+// IssueStrategy GitLab issues are referenced like "group/repo#123"
+func (t GitLabTracker) IssueStrategy() IssueStrategy {
+	return GitLabIssueStrategy{}
+}
+
+// GetIssue get an issue by a reference like "group/repo#123"
+func (t GitLabTracker) GetIssue(
+	ctx context.Context,
+	reference string,
+) (*Issue, error) {
+	project, number, err := repositoryIssue(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		IID       int      `json:"iid"`
+		Title     string   `json:"title"`
+		WebURL    string   `json:"web_url"`
+		Labels    []string `json:"labels"`
+		IssueType string   `json:"issue_type"`
+	}{}
+
+	headers := map[string]string{}
+
+	if t.Token != "" {
+		headers["PRIVATE-TOKEN"] = t.Token
+	}
+
+	if err := trackerRequest(
+		ctx,
+		t.Client,
+		"GET",
+		apiURL(
+			t.Endpoint,
+			gitLabAPI,
+			"projects/"+url.PathEscape(project)+"/issues/"+number,
+		),
+		headers,
+		nil,
+		&result,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to get issue from gitlab")
+	}
+
+	issue := &Issue{
+		Key:    strconv.Itoa(result.IID),
+		Title:  result.Title,
+		Type:   result.IssueType,
+		URL:    result.WebURL,
+		Labels: result.Labels,
+	}
+
+	if issue.Labels == nil {
+		issue.Labels = []string{}
+	}
+
+	return issue, nil
+}
@@ -0,0 +1,95 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitLabIssueStrategy", func() {
+	references := map[string]string{
+		"https://gitlab.com/group/sub/repo/-/issues/12":   "group/sub/repo#12",
+		"https://gitlab.example.com/group/repo/issues/12": "group/repo#12",
+		"https://gitlab.com/group/repo/-/work_items/12":   "group/repo#12",
+		"group/sub/repo#12": "group/sub/repo#12",
+	}
+
+	for raw, expected := range references {
+		raw, expected := raw, expected
+
+		It("Reads "+raw, func() {
+			actual, err := GitLabIssueStrategy{}.GetIssue(raw)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(expected))
+		})
+	}
+})
+
+var _ = Describe("GitLabTracker", func() {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	var server *httptest.Server
+	var subject GitLabTracker
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		bodies = []map[string]interface{}{}
+		server = testTrackerServer(`{
+			"iid": 12,
+			"title": "Login page",
+			"web_url": "https://gitlab.com/group/sub/repo/-/issues/12",
+			"labels": ["frontend"],
+			"issue_type": "incident"
+		}`, &requests, &bodies)
+		subject = GitLabTracker{
+			Client:   http.DefaultClient,
+			Endpoint: server.URL + "/api/v4",
+			Token:    "secret",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Gets issues", func() {
+		actual, err := subject.GetIssue(context.Background(), "group/sub/repo#12")
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal(&Issue{
+			Key:    "12",
+			Title:  "Login page",
+			Type:   "incident",
+			Labels: []string{"frontend"},
+			URL:    "https://gitlab.com/group/sub/repo/-/issues/12",
+		}))
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].URL.EscapedPath()).To(Equal(
+			"/api/v4/projects/group%2Fsub%2Frepo/issues/12",
+		))
+		Expect(requests[0].Header.Get("PRIVATE-TOKEN")).To(Equal("secret"))
+	})
+})
@@ -0,0 +1,96 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// jiraTrackerFields are the fields needed to fill in an Issue
+const jiraTrackerFields = "summary,issuetype,labels"
+
+// JiraTracker gets issues from Jira as a Tracker, for code that works with
+// any tracker. Templates for Jira issues can use every field of the issue,
+// the Jira type does that
+type JiraTracker struct {
+	Jira *Jira
+}
+
+// jiraIssueStrategy picks the strategy for each issue, as the Jira issue
+// strategies are picked by URL
+type jiraIssueStrategy struct {
+}
+
+func (s jiraIssueStrategy) GetIssue(rawIssue string) (string, error) {
+	issueURL, err := url.Parse(rawIssue)
+	if err != nil {
+		issueURL = nil
+	}
+
+	return MakeIssueStrategy(issueURL).GetIssue(rawIssue)
+}
+
+// IssueStrategy Jira issues are referenced by their keys, e.g. "TST-123"
+func (t JiraTracker) IssueStrategy() IssueStrategy {
+	return jiraIssueStrategy{}
+}
+
+// GetIssue get an issue by its key
+func (t JiraTracker) GetIssue(
+	ctx context.Context,
+	reference string,
+) (*Issue, error) {
+	issue, err := t.Jira.getIssue(
+		ctx,
+		reference,
+		&jira.GetQueryOptions{Fields: jiraTrackerFields},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	data := t.Jira.templateData(ctx, issue)
+
+	return &Issue{
+		Key:    issue.Key,
+		Title:  data.Title(),
+		Type:   data.Type(),
+		Labels: data.Labels(),
+		URL:    data.URL(),
+	}, nil
+}
+
+// jiraIssueURL the link to an issue in Jira, worked out from the link to it
+// in the API e.g. "https://example.com/jira/rest/api/2/issue/10000"
+func jiraIssueURL(issue *jira.Issue) string {
+	if issue == nil || issue.Self == "" || issue.Key == "" {
+		return ""
+	}
+
+	apiPath := strings.Index(issue.Self, "/rest/api/")
+	if apiPath < 0 {
+		return ""
+	}
+
+	return issue.Self[:apiPath] + "/browse/" + issue.Key
+}
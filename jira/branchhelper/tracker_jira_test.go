@@ -0,0 +1,79 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	"github.com/andygrunwald/go-jira"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JiraTracker", func() {
+	It("Gets issues from Jira", func() {
+		var query url.Values
+		client, stop := testJiraClient(func(w http.ResponseWriter, r *http.Request) {
+			query = r.URL.Query()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{
+				"key": "TST-123",
+				"self": "https://example.com/jira/rest/api/2/issue/10000",
+				"fields": {
+					"summary": "Login page",
+					"issuetype": {"name": "Story"},
+					"labels": ["frontend"]
+				}
+			}`))
+		})
+		defer stop()
+
+		subject := JiraTracker{Jira: NewJira(client)}
+		reference, err := subject.IssueStrategy().GetIssue(
+			"https://example.com/jira/browse/TST-123",
+		)
+		Expect(err).To(BeNil())
+		Expect(reference).To(Equal("TST-123"))
+
+		actual, err := subject.GetIssue(context.Background(), reference)
+
+		Expect(err).To(BeNil())
+		Expect(query.Get("fields")).To(Equal("summary,issuetype,labels"))
+		Expect(actual).To(Equal(&Issue{
+			Key:    "TST-123",
+			Title:  "Login page",
+			Type:   "Story",
+			Labels: []string{"frontend"},
+			URL:    "https://example.com/jira/browse/TST-123",
+		}))
+	})
+	It("Fails when Jira does", func() {
+		subject := JiraTracker{Jira: &Jira{Client: testGetIssue{
+			err: json.Unmarshal([]byte("{"), &jira.Issue{}),
+		}}}
+
+		_, err := subject.GetIssue(context.Background(), "TST-123")
+
+		Expect(err).ToNot(BeNil())
+	})
+})
@@ -0,0 +1,163 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const linearAPI = "https://api.linear.app/graphql"
+
+// linearIssueQuery asks the Linear GraphQL API for the details of an issue
+const linearIssueQuery = `query Issue($id: String!) {
+  issue(id: $id) {
+    identifier
+    title
+    url
+    labels { nodes { name } }
+  }
+}`
+
+// linearPathReg matches the path of issues on Linear, e.g.
+// "/workspace/issue/ENG-123/the-title"
+var linearPathReg = regexp.MustCompile(`^/[^/]+/issue/([A-Za-z0-9]+-\d+)`)
+
+// LinearIssueStrategy takes a Linear issue URL, or an identifier like
+// "ENG-123", and turns it into an identifier
+type LinearIssueStrategy struct {
+}
+
+// Applicable the Linear strategy handles issues on linear.app
+func (s LinearIssueStrategy) Applicable(issueURL *url.URL) bool {
+	return issueURL != nil &&
+		strings.HasSuffix(issueURL.Host, "linear.app") &&
+		linearPathReg.MatchString(issueURL.Path)
+}
+
+// GetIssue extracts the identifier like "ENG-123" from the issue
+func (s LinearIssueStrategy) GetIssue(rawIssue string) (string, error) {
+	issueURL, err := url.Parse(rawIssue)
+	if err != nil {
+		return "", errors.Wrap(err, "issue url invalid")
+	}
+
+	if issueURL.Host == "" {
+		return rawIssue, nil
+	}
+
+	matches := linearPathReg.FindStringSubmatch(issueURL.Path)
+	if matches == nil {
+		return "", errors.Errorf("%q isn't a Linear issue", rawIssue)
+	}
+
+	return matches[1], nil
+}
+
+// LinearTracker gets issues from Linear with its GraphQL API
+type LinearTracker struct {
+	Client   HTTPDoer
+	Endpoint string
+	Token    string
+}
+
+// IssueStrategy Linear issues are referenced by identifiers like "ENG-123"
+func (t LinearTracker) IssueStrategy() IssueStrategy {
+	return LinearIssueStrategy{}
+}
+
+// GetIssue get an issue by an identifier like "ENG-123"
+func (t LinearTracker) GetIssue(
+	ctx context.Context,
+	reference string,
+) (*Issue, error) {
+	result := struct {
+		Data struct {
+			Issue *struct {
+				Identifier string `json:"identifier"`
+				Title      string `json:"title"`
+				URL        string `json:"url"`
+				Labels     struct {
+					Nodes []struct {
+						Name string `json:"name"`
+					} `json:"nodes"`
+				} `json:"labels"`
+			} `json:"issue"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}{}
+
+	headers := map[string]string{}
+
+	// Linear API keys are sent as they are, so OAuth tokens can be given as
+	// "Bearer <token>"
+	if t.Token != "" {
+		headers["Authorization"] = t.Token
+	}
+
+	endpoint := t.Endpoint
+	if endpoint == "" {
+		endpoint = linearAPI
+	}
+
+	if err := trackerRequest(
+		ctx,
+		t.Client,
+		"POST",
+		endpoint,
+		headers,
+		map[string]interface{}{
+			"query":     linearIssueQuery,
+			"variables": map[string]string{"id": reference},
+		},
+		&result,
+	); err != nil {
+		return nil, errors.Wrap(err, "failed to get issue from linear")
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, errors.Errorf(
+			"failed to get issue from linear: %s",
+			result.Errors[0].Message,
+		)
+	}
+
+	if result.Data.Issue == nil {
+		return nil, errors.Errorf("linear has no issue %s", reference)
+	}
+
+	issue := &Issue{
+		Key:    result.Data.Issue.Identifier,
+		Title:  result.Data.Issue.Title,
+		URL:    result.Data.Issue.URL,
+		Labels: []string{},
+	}
+
+	for _, label := range result.Data.Issue.Labels.Nodes {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+
+	return issue, nil
+}
@@ -0,0 +1,117 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LinearIssueStrategy", func() {
+	references := map[string]string{
+		"https://linear.app/example/issue/ENG-12/login-page": "ENG-12",
+		"https://linear.app/example/issue/ENG-12":            "ENG-12",
+		"ENG-12": "ENG-12",
+	}
+
+	for raw, expected := range references {
+		raw, expected := raw, expected
+
+		It("Reads "+raw, func() {
+			actual, err := LinearIssueStrategy{}.GetIssue(raw)
+
+			Expect(err).To(BeNil())
+			Expect(actual).To(Equal(expected))
+		})
+	}
+})
+
+var _ = Describe("LinearTracker", func() {
+	var requests []*http.Request
+	var bodies []map[string]interface{}
+	var response string
+	var server *httptest.Server
+	var subject LinearTracker
+
+	BeforeEach(func() {
+		requests = []*http.Request{}
+		bodies = []map[string]interface{}{}
+		response = `{"data": {"issue": {
+			"identifier": "ENG-12",
+			"title": "Login page",
+			"url": "https://linear.app/example/issue/ENG-12/login-page",
+			"labels": {"nodes": [{"name": "frontend"}]}
+		}}}`
+	})
+
+	JustBeforeEach(func() {
+		server = testTrackerServer(response, &requests, &bodies)
+		subject = LinearTracker{
+			Client:   http.DefaultClient,
+			Endpoint: server.URL + "/graphql",
+			Token:    "lin_api_secret",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Gets issues", func() {
+		actual, err := subject.GetIssue(context.Background(), "ENG-12")
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal(&Issue{
+			Key:    "ENG-12",
+			Title:  "Login page",
+			Labels: []string{"frontend"},
+			URL:    "https://linear.app/example/issue/ENG-12/login-page",
+		}))
+		Expect(requests[0].Header.Get("Authorization")).To(Equal("lin_api_secret"))
+		Expect(bodies[0]["variables"]).To(Equal(map[string]interface{}{"id": "ENG-12"}))
+	})
+	Context("When Linear has errors", func() {
+		BeforeEach(func() {
+			response = `{"errors": [{"message": "Entity not found"}]}`
+		})
+
+		It("Fails", func() {
+			_, err := subject.GetIssue(context.Background(), "ENG-12")
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("Entity not found"))
+		})
+	})
+	Context("When the issue doesn't exist", func() {
+		BeforeEach(func() {
+			response = `{"data": {"issue": null}}`
+		})
+
+		It("Fails", func() {
+			_, err := subject.GetIssue(context.Background(), "ENG-12")
+
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
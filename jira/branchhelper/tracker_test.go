@@ -0,0 +1,136 @@
+// jira-branch-helper - Build a string that can be used for a branch name from
+// the details in a Jira ticket
+//
+// 	Copyright (C) 2017 Billie Alice Thompson
+//
+// 	This program is free software: you can redistribute it and/or modify
+// 	it under the terms of the GNU General Public License as published by
+// 	the Free Software Foundation, either version 3 of the License, or
+// 	(at your option) any later version.
+//
+// 	This program is distributed in the hope that it will be useful,
+// 	but WITHOUT ANY WARRANTY; without even the implied warranty of
+// 	MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// 	GNU General Public License for more details.
+//
+// 	You should have received a copy of the GNU General Public License
+// 	along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package branchhelper_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/PurpleBooth/jira-branch-helper/jira/branchhelper"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DetectTracker", func() {
+	urls := map[string]string{
+		"https://github.com/org/repo/issues/12":              TrackerGitHub,
+		"https://github.example.com/org/repo/pull/12":        TrackerGitHub,
+		"https://gitlab.com/group/sub/repo/-/issues/12":      TrackerGitLab,
+		"https://linear.app/example/issue/ENG-12/login-page": TrackerLinear,
+		"https://example.atlassian.net/browse/TST-12":        TrackerJira,
+		"https://github.com/org/repo":                        TrackerJira,
+		"TST-12":                                             TrackerJira,
+	}
+
+	for rawURL, expected := range urls {
+		rawURL, expected := rawURL, expected
+
+		It("Detects "+rawURL, func() {
+			issueURL, err := url.Parse(rawURL)
+			Expect(err).To(BeNil())
+
+			Expect(DetectTracker(issueURL)).To(Equal(expected))
+		})
+	}
+})
+
+var _ = Describe("NewTracker", func() {
+	It("Builds trackers by kind", func() {
+		for _, kind := range []string{TrackerGitHub, TrackerGitLab, TrackerLinear} {
+			tracker, err := NewTracker(kind, http.DefaultClient, "", "")
+
+			Expect(err).To(BeNil())
+			Expect(tracker).ToNot(BeNil())
+		}
+	})
+	It("Fails for unknown kinds", func() {
+		_, err := NewTracker("trello", http.DefaultClient, "", "")
+
+		Expect(err).ToNot(BeNil())
+	})
+})
+
+var _ = Describe("Rendering tracker issues", func() {
+	issue := &Issue{
+		Key:    "12",
+		Title:  "Login page",
+		Type:   "Bug",
+		Labels: []string{"frontend", "auth"},
+		URL:    "https://github.com/org/repo/issues/12",
+	}
+
+	It("Uses the details of the issue", func() {
+		templ, err := (&Jira{}).ParseTemplate(
+			`{{.Type | ToLower}}/{{.Key}}-{{.Title | KebabCase}}-{{.Labels | Join "-"}}`,
+		)
+		Expect(err).To(BeNil())
+
+		actual, err := NewRenderer(templ).RenderTrackerIssue(issue)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("bug/12-login-page-frontend-auth"))
+	})
+	It("Can't look up fields", func() {
+		templ, err := (&Jira{}).ParseTemplate(`{{Field "Team"}}`)
+		Expect(err).To(BeNil())
+
+		_, err = NewRenderer(templ).RenderTrackerIssue(issue)
+
+		Expect(err).ToNot(BeNil())
+	})
+	It("Works with the same template for Jira issues", func() {
+		subject := summaryJira("Login page")
+		actual, err := subject.FormatIssue(
+			"TST-123",
+			"{{.Key}}-{{.Title | KebabCase}}",
+		)
+
+		Expect(err).To(BeNil())
+		Expect(actual).To(Equal("TST-123-login-page"))
+	})
+})
+
+// testTrackerServer a local stand in for a tracker API, which answers every
+// request with the response and records what was asked
+func testTrackerServer(
+	response string,
+	requests *[]*http.Request,
+	bodies *[]map[string]interface{},
+) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r)
+
+		if r.Body != nil && r.Method == "POST" {
+			body := map[string]interface{}{}
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			*bodies = append(*bodies, body)
+		}
+
+		if response == "" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+}